@@ -0,0 +1,122 @@
+package mgr
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// batch accumulates lines and writes them to the connection in batches
+// bounded by the MaxBatchLines and MaxBatchBytes of the configuration.
+type batch struct {
+	config *Config
+	buf    *bytes.Buffer
+	lines  int
+
+	batches  int
+	failures int
+	firstErr error
+
+	// dropErr, if set, is the reason why every line added to the batch is dropped.
+	dropErr error
+
+	bytesSent    int64
+	linesSent    int64
+	droppedLines int64
+}
+
+func newBatch(config *Config) *batch {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return &batch{config: config, buf: buf}
+}
+
+// add appends the line to the batch, writing the batch first if the line would exceed its limits.
+func (b *batch) add(line []byte) {
+	if b.dropErr != nil {
+		b.droppedLines++
+		return
+	}
+
+	if b.full(len(line)) {
+		b.flush()
+	}
+
+	b.buf.Write(line)
+	b.lines++
+}
+
+// drop makes the batch drop the lines added to it instead of writing them, because of `err`.
+func (b *batch) drop(err error) {
+	b.dropErr = err
+}
+
+func (b *batch) full(n int) bool {
+	if b.config == nil || b.lines == 0 {
+		return false
+	}
+
+	maxLines, maxBytes := b.config.MaxBatchLines, b.config.MaxBatchBytes
+
+	return (maxLines > 0 && b.lines >= maxLines) || (maxBytes > 0 && b.buf.Len()+n > maxBytes)
+}
+
+// flush writes the batch. If the write fails the connection is dropped, the next batch
+// dials a new one; the lines of the failed batch are lost.
+func (b *batch) flush() {
+	if b.lines == 0 {
+		return
+	}
+
+	b.batches++
+	if err := b.write(); err != nil {
+		b.failures++
+		b.droppedLines += int64(b.lines)
+		if b.firstErr == nil {
+			b.firstErr = err
+		}
+	} else {
+		b.bytesSent += int64(b.buf.Len())
+		b.linesSent += int64(b.lines)
+	}
+
+	b.buf.Reset()
+	b.lines = 0
+}
+
+func (b *batch) write() error {
+	if conn == nil {
+		if err := dial(b.config); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Write(b.buf.Bytes())
+	if err != nil {
+		conn = nil
+		return &exportError{kind: "write", err: err}
+	}
+
+	return nil
+}
+
+// err returns the error of the first failed batch, if any.
+func (b *batch) err() error {
+	switch {
+	case b.dropErr != nil:
+		return b.dropErr
+	case b.failures == 0:
+		return nil
+	case b.batches == 1:
+		return b.firstErr
+	default:
+		return fmt.Errorf("%d of %d batches failed, first error: %w", b.failures, b.batches, b.firstErr)
+	}
+}
+
+func (b *batch) release() {
+	if b.buf.Cap() <= maxPooledBufferSize {
+		bufPool.Put(b.buf)
+	}
+	b.buf = nil
+}
@@ -0,0 +1,93 @@
+package mgr
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writesRecorder records each call to Write, failing the calls listed in fail.
+type writesRecorder struct {
+	writes []string
+	fail   map[int]bool
+}
+
+func (w *writesRecorder) Write(p []byte) (int, error) {
+	n := len(w.writes)
+	w.writes = append(w.writes, string(p))
+	if w.fail[n] {
+		return 0, errors.New("broken pipe")
+	}
+	return len(p), nil
+}
+
+func resetWithRecorder(w *writesRecorder) (dials *int, fn func()) {
+	_, fn = reset()
+
+	dials = new(int)
+	dialFn = func(_ *Config) (io.Writer, error) {
+		*dials++
+		return w, nil
+	}
+
+	return dials, fn
+}
+
+func TestBatchMaxLines(t *testing.T) {
+	var w writesRecorder
+	_, fn := resetWithRecorder(&w)
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+	NewInt("c")
+
+	err := report(&Config{Clock: fakeClock(100), MaxBatchLines: 2})
+	require.Nil(t, err)
+	require.Equal(t, []string{"a 0 100\nb 0 100\n", "c 0 100\n"}, w.writes)
+}
+
+func TestBatchMaxBytes(t *testing.T) {
+	var w writesRecorder
+	_, fn := resetWithRecorder(&w)
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+	NewInt("this.is.a.long.key")
+
+	err := report(&Config{Clock: fakeClock(100), MaxBatchBytes: 10})
+	require.Nil(t, err)
+	require.Equal(t, []string{"a 0 100\n", "b 0 100\n", "this.is.a.long.key 0 100\n"}, w.writes)
+}
+
+func TestBatchFailure(t *testing.T) {
+	w := writesRecorder{fail: map[int]bool{1: true}}
+	dials, fn := resetWithRecorder(&w)
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+	NewInt("c")
+
+	err := report(&Config{Clock: fakeClock(100), MaxBatchLines: 1})
+	require.NotNil(t, err)
+	require.Equal(t, "1 of 3 batches failed, first error: broken pipe", err.Error())
+	require.Equal(t, []string{"a 0 100\n", "b 0 100\n", "c 0 100\n"}, w.writes)
+	require.Equal(t, 2, *dials)
+}
+
+func TestBatchRelease(t *testing.T) {
+	b := newBatch(nil)
+	b.buf.Grow(maxPooledBufferSize + 1)
+	big := b.buf
+	b.release()
+
+	for i := 0; i < 10; i++ {
+		buf := bufPool.Get().(*bytes.Buffer)
+		require.False(t, buf == big)
+	}
+}
@@ -0,0 +1,96 @@
+package mgr
+
+import (
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// BucketHistogram is a histogram with fixed, user-defined bucket boundaries that satisfies the Var interface.
+//
+// Each bucket counts the values lower than or equal to its boundary, the counts are cumulative.
+// Unlike the percentiles reported by Histogram, these counts can be summed across hosts in Graphite.
+type BucketHistogram struct {
+	key    string
+	bounds []int64
+
+	// counts[i] is the number of values in (bounds[i-1], bounds[i]].
+	// The last element counts values greater than every boundary.
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+// NewBucketHistogram creates a BucketHistogram with the given boundaries and publishes it.
+func NewBucketHistogram(name string, bounds ...int64) *BucketHistogram {
+	h := &BucketHistogram{key: name}
+	h.Init(bounds...)
+	Publish(h)
+
+	return h
+}
+
+// Init initializes the histogram with the given boundaries. Must be called before attempting to observe a value.
+// The boundaries are sorted and the duplicates removed.
+// Note that NewBucketHistogram already initializes the histogram.
+func (h *BucketHistogram) Init(bounds ...int64) *BucketHistogram {
+	h.bounds = append([]int64(nil), bounds...)
+	sort.Sort(int64slice(h.bounds))
+
+	n := 0
+	for i, bound := range h.bounds {
+		if i == 0 || bound != h.bounds[n-1] {
+			h.bounds[n] = bound
+			n++
+		}
+	}
+	h.bounds = h.bounds[:n]
+	h.counts = make([]int64, len(h.bounds)+1)
+
+	return h
+}
+
+// Observe atomically records the value `val`.
+func (h *BucketHistogram) Observe(val int64) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return h.bounds[i] >= val })
+
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, val)
+}
+
+// ObserveSince records the time elapsed since `t` in nanoseconds.
+func (h *BucketHistogram) ObserveSince(t time.Time) {
+	h.Observe(int64(time.Since(t)))
+}
+
+// Items returns one le_<bound> item per boundary holding the number of values lower than or equal to it,
+// followed by the total count and sum of the observed values.
+func (h *BucketHistogram) Items() []KeyValue { return formatSamples(h.Samples()) }
+
+// Samples returns the same values as Items as counters.
+func (h *BucketHistogram) Samples() []Sample {
+	counter := func(name string, v int64) Sample {
+		return Sample{Key: JoinKey(h.key, name), Value: Int64Value(v), Kind: KindCounter}
+	}
+
+	res := make([]Sample, 0, len(h.bounds)+2)
+
+	var cumulative int64
+	for j, bound := range h.bounds {
+		cumulative += atomic.LoadInt64(&h.counts[j])
+		res = append(res, counter("le_"+strconv.FormatInt(bound, 10), cumulative))
+	}
+	res = append(res,
+		counter("count", atomic.LoadInt64(&h.count)),
+		counter("sum", atomic.LoadInt64(&h.sum)),
+	)
+
+	return res
+}
+
+var (
+	_ Var     = (*BucketHistogram)(nil)
+	_ Sampler = (*BucketHistogram)(nil)
+)
@@ -0,0 +1,59 @@
+package mgr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketHistogram(t *testing.T) {
+	h := NewBucketHistogram("latency", 100, 10, 50)
+
+	for _, v := range []int64{1, 10, 11, 50, 99, 100, 101, 5000} {
+		h.Observe(v)
+	}
+
+	require.Equal(t, []KeyValue{
+		{"latency.le_10", "2"},
+		{"latency.le_50", "4"},
+		{"latency.le_100", "6"},
+		{"latency.count", "8"},
+		{"latency.sum", "5372"},
+	}, h.Items())
+}
+
+func TestBucketHistogramDuplicateBounds(t *testing.T) {
+	var h BucketHistogram
+	h.key = "latency"
+	h.Init(10, 10, 5)
+
+	h.Observe(7)
+	h.Observe(10)
+
+	require.Equal(t, []KeyValue{
+		{"latency.le_5", "0"},
+		{"latency.le_10", "2"},
+		{"latency.count", "2"},
+		{"latency.sum", "17"},
+	}, h.Items())
+}
+
+func TestBucketHistogramConcurrent(t *testing.T) {
+	var h BucketHistogram
+	h.Init(10)
+
+	var wg sync.WaitGroup
+	wg.Add(4000)
+	for j := 0; j < 4000; j++ {
+		go func(j int) {
+			h.Observe(int64(j % 20))
+			wg.Done()
+		}(j)
+	}
+	wg.Wait()
+
+	items := h.Items()
+	require.Equal(t, "2200", items[0].Value)
+	require.Equal(t, "4000", items[1].Value)
+}
@@ -0,0 +1,201 @@
+package mgr
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unlimitedCgroupValue is the threshold above which a cgroup v1 limit is considered unlimited:
+// the kernel reports an unlimited memory limit as the largest page aligned int64.
+const unlimitedCgroupValue = 1 << 62
+
+// CgroupStats is a variable reporting the resource limits and usage of the cgroup of the
+// current process that satisfies the Var interface.
+//
+// Both cgroup v1 and v2 are supported, the version is detected when reading the statistics.
+// It reports the memory usage and limit, the CPU quota in cores and the CPU throttling,
+// and the number of PIDs and their limit. Unlimited limits and files which can't be read are skipped.
+//
+// The cgroup filesystem is expected at /sys/fs/cgroup with the cgroup of the process at its root,
+// which is the case inside a container.
+type CgroupStats struct {
+	key  string
+	root string
+}
+
+// NewCgroupStats creates a CgroupStats and publishes it.
+func NewCgroupStats(name string) *CgroupStats {
+	c := &CgroupStats{key: name, root: "/sys/fs/cgroup"}
+	Publish(c)
+
+	return c
+}
+
+// cgroupV2 returns true if the cgroup filesystem at `root` uses the unified hierarchy.
+func cgroupV2(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+func (c *CgroupStats) Items() []KeyValue { return formatSamples(c.Samples()) }
+
+// Samples reads the cgroup statistics.
+func (c *CgroupStats) Samples() []Sample {
+	var st cgroupStats
+	if cgroupV2(c.root) {
+		st = readCgroupV2(c.root)
+	} else {
+		st = readCgroupV1(c.root)
+	}
+
+	var res []Sample
+	add := func(name string, v Value, kind Kind) {
+		res = append(res, Sample{Key: JoinKey(c.key, name), Value: v, Kind: kind})
+	}
+
+	if st.memoryUsage.ok {
+		add("memory.usage_bytes", Uint64Value(st.memoryUsage.v), KindGauge)
+	}
+	if st.memoryLimit.ok {
+		add("memory.limit_bytes", Uint64Value(st.memoryLimit.v), KindGauge)
+		if st.memoryUsage.ok && st.memoryLimit.v > 0 {
+			add("memory.utilization", Float64Value(float64(st.memoryUsage.v)/float64(st.memoryLimit.v)), KindGauge)
+		}
+	}
+	if st.cpuQuota.ok && st.cpuPeriod.ok && st.cpuPeriod.v > 0 {
+		add("cpu.quota_cores", Float64Value(float64(st.cpuQuota.v)/float64(st.cpuPeriod.v)), KindGauge)
+	}
+	if st.periods.ok {
+		add("cpu.periods", Uint64Value(st.periods.v), KindCounter)
+	}
+	if st.throttledPeriods.ok {
+		add("cpu.throttled_periods", Uint64Value(st.throttledPeriods.v), KindCounter)
+	}
+	if st.throttledTime.ok {
+		add("cpu.throttled_seconds", Float64Value(time.Duration(st.throttledTime.v).Seconds()), KindCounter)
+	}
+	if st.pids.ok {
+		add("pids.current", Uint64Value(st.pids.v), KindGauge)
+	}
+	if st.pidsLimit.ok {
+		add("pids.limit", Uint64Value(st.pidsLimit.v), KindGauge)
+	}
+
+	return res
+}
+
+type optionalUint64 struct {
+	v  uint64
+	ok bool
+}
+
+type cgroupStats struct {
+	memoryUsage      optionalUint64
+	memoryLimit      optionalUint64
+	cpuQuota         optionalUint64
+	cpuPeriod        optionalUint64
+	periods          optionalUint64
+	throttledPeriods optionalUint64
+	// throttledTime is in nanoseconds.
+	throttledTime optionalUint64
+	pids          optionalUint64
+	pidsLimit     optionalUint64
+}
+
+// readCgroupValue reads a file holding a single value.
+// Values equal to "max", negative or above unlimitedCgroupValue are considered unlimited and skipped.
+func readCgroupValue(path string) optionalUint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return optionalUint64{}
+	}
+	return parseCgroupValue(strings.TrimSpace(string(data)))
+}
+
+func parseCgroupValue(s string) optionalUint64 {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v >= unlimitedCgroupValue {
+		return optionalUint64{}
+	}
+	return optionalUint64{v, true}
+}
+
+func lookupCgroupValue(m map[string]uint64, key string) optionalUint64 {
+	v, ok := m[key]
+	return optionalUint64{v, ok}
+}
+
+func readCgroupV2(root string) (res cgroupStats) {
+	res.memoryUsage = readCgroupValue(filepath.Join(root, "memory.current"))
+	res.memoryLimit = readCgroupValue(filepath.Join(root, "memory.max"))
+
+	// cpu.max holds "<quota> <period>", the quota being "max" when unlimited.
+	if data, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			res.cpuQuota = parseCgroupValue(fields[0])
+			res.cpuPeriod = parseCgroupValue(fields[1])
+		}
+	}
+
+	if stat, err := readProcKeyValues(filepath.Join(root, "cpu.stat"), " "); err == nil {
+		res.periods = lookupCgroupValue(stat, "nr_periods")
+		res.throttledPeriods = lookupCgroupValue(stat, "nr_throttled")
+		if v, ok := stat["throttled_usec"]; ok {
+			res.throttledTime = optionalUint64{v * uint64(time.Microsecond), true}
+		}
+	}
+
+	res.pids = readCgroupValue(filepath.Join(root, "pids.current"))
+	res.pidsLimit = readCgroupValue(filepath.Join(root, "pids.max"))
+
+	return
+}
+
+// cgroupV1Controller returns the directory of the controller `name`, which can be mounted
+// along other controllers, for example at cpu,cpuacct.
+func cgroupV1Controller(root, name string) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return filepath.Join(root, name)
+	}
+
+	for _, entry := range entries {
+		for _, controller := range strings.Split(entry.Name(), ",") {
+			if controller == name {
+				return filepath.Join(root, entry.Name())
+			}
+		}
+	}
+
+	return filepath.Join(root, name)
+}
+
+func readCgroupV1(root string) (res cgroupStats) {
+	memory := cgroupV1Controller(root, "memory")
+	res.memoryUsage = readCgroupValue(filepath.Join(memory, "memory.usage_in_bytes"))
+	res.memoryLimit = readCgroupValue(filepath.Join(memory, "memory.limit_in_bytes"))
+
+	cpu := cgroupV1Controller(root, "cpu")
+	res.cpuQuota = readCgroupValue(filepath.Join(cpu, "cpu.cfs_quota_us"))
+	res.cpuPeriod = readCgroupValue(filepath.Join(cpu, "cpu.cfs_period_us"))
+
+	if stat, err := readProcKeyValues(filepath.Join(cpu, "cpu.stat"), " "); err == nil {
+		res.periods = lookupCgroupValue(stat, "nr_periods")
+		res.throttledPeriods = lookupCgroupValue(stat, "nr_throttled")
+		res.throttledTime = lookupCgroupValue(stat, "throttled_time")
+	}
+
+	pids := cgroupV1Controller(root, "pids")
+	res.pids = readCgroupValue(filepath.Join(pids, "pids.current"))
+	res.pidsLimit = readCgroupValue(filepath.Join(pids, "pids.max"))
+
+	return
+}
+
+var (
+	_ Var     = (*CgroupStats)(nil)
+	_ Sampler = (*CgroupStats)(nil)
+)
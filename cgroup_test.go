@@ -0,0 +1,41 @@
+package mgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupV2(t *testing.T) {
+	c := &CgroupStats{key: "cgroup", root: "testdata/cgroup/v2"}
+
+	require.Equal(t, []Sample{
+		{Key: "cgroup.memory.usage_bytes", Value: Uint64Value(104857600), Kind: KindGauge},
+		{Key: "cgroup.memory.limit_bytes", Value: Uint64Value(536870912), Kind: KindGauge},
+		{Key: "cgroup.memory.utilization", Value: Float64Value(0.1953125), Kind: KindGauge},
+		{Key: "cgroup.cpu.quota_cores", Value: Float64Value(1.5), Kind: KindGauge},
+		{Key: "cgroup.cpu.periods", Value: Uint64Value(1000), Kind: KindCounter},
+		{Key: "cgroup.cpu.throttled_periods", Value: Uint64Value(25), Kind: KindCounter},
+		{Key: "cgroup.cpu.throttled_seconds", Value: Float64Value(1.5), Kind: KindCounter},
+		{Key: "cgroup.pids.current", Value: Uint64Value(12), Kind: KindGauge},
+	}, c.Samples())
+}
+
+func TestCgroupV1(t *testing.T) {
+	c := &CgroupStats{key: "cgroup", root: "testdata/cgroup/v1"}
+
+	require.Equal(t, []Sample{
+		{Key: "cgroup.memory.usage_bytes", Value: Uint64Value(52428800), Kind: KindGauge},
+		{Key: "cgroup.cpu.quota_cores", Value: Float64Value(0.5), Kind: KindGauge},
+		{Key: "cgroup.cpu.periods", Value: Uint64Value(400), Kind: KindCounter},
+		{Key: "cgroup.cpu.throttled_periods", Value: Uint64Value(10), Kind: KindCounter},
+		{Key: "cgroup.cpu.throttled_seconds", Value: Float64Value(2.5), Kind: KindCounter},
+		{Key: "cgroup.pids.current", Value: Uint64Value(7), Kind: KindGauge},
+		{Key: "cgroup.pids.limit", Value: Uint64Value(100), Kind: KindGauge},
+	}, c.Samples())
+}
+
+func TestCgroupMissing(t *testing.T) {
+	c := &CgroupStats{key: "cgroup", root: "testdata/nonexistent"}
+	require.Empty(t, c.Samples())
+}
@@ -0,0 +1,88 @@
+package mgr
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultInterval is the export interval used when Config.Interval is zero.
+const DefaultInterval = 1 * time.Minute
+
+// Validate checks that the configuration is usable by Export.
+// The returned errors wrap ErrInvalidConfig.
+func (c *Config) Validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: nil config", ErrInvalidConfig)
+	}
+
+	if c.Addr == "" {
+		return fmt.Errorf("%w: missing address", ErrInvalidConfig)
+	}
+	if _, err := net.ResolveTCPAddr("tcp", c.Addr); err != nil {
+		return fmt.Errorf("%w: unresolvable address %q: %v", ErrInvalidConfig, c.Addr, err)
+	}
+
+	if c.Interval < 0 {
+		return fmt.Errorf("%w: negative interval %v", ErrInvalidConfig, c.Interval)
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("%w: negative jitter %v", ErrInvalidConfig, c.Jitter)
+	}
+	if c.MaxBatchLines < 0 || c.MaxBatchBytes < 0 {
+		return fmt.Errorf("%w: negative batch size", ErrInvalidConfig)
+	}
+
+	prefix, err := ExpandPrefix(c.Prefix)
+	if err != nil {
+		return fmt.Errorf("%w: invalid prefix %q: %v", ErrInvalidConfig, c.Prefix, err)
+	}
+	if err := validateKey(prefix); err != nil {
+		return fmt.Errorf("%w: invalid prefix %q: %v", ErrInvalidConfig, c.Prefix, err)
+	}
+	if err := validateKey(c.StatsPrefix); err != nil {
+		return fmt.Errorf("%w: invalid stats prefix %q: %v", ErrInvalidConfig, c.StatsPrefix, err)
+	}
+
+	return nil
+}
+
+// validateKey checks that `key` can be used as a Graphite metric path.
+// An empty key is valid.
+func validateKey(key string) error {
+	if key == "" {
+		return nil
+	}
+
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("contains the character %q", r)
+		}
+	}
+	for _, node := range strings.Split(key, ".") {
+		if node == "" {
+			return fmt.Errorf("contains an empty node")
+		}
+	}
+
+	return nil
+}
+
+// withDefaults returns a copy of the configuration with the defaults applied and the prefix expanded.
+// The configuration must be valid.
+func (c *Config) withDefaults() *Config {
+	res := *c
+
+	res.Prefix, _ = ExpandPrefix(c.Prefix)
+	if res.Interval == 0 {
+		res.Interval = DefaultInterval
+	}
+	if res.Logger == nil {
+		res.Logger = log.Printf
+	}
+
+	return &res
+}
@@ -0,0 +1,123 @@
+package mgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		config *Config
+		err    string
+	}{
+		{nil, "invalid config: nil config"},
+		{&Config{}, "invalid config: missing address"},
+		{&Config{Addr: "localhost"}, `invalid config: unresolvable address "localhost": address localhost: missing port in address`},
+		{&Config{Addr: "localhost:2003", Interval: -time.Second}, "invalid config: negative interval -1s"},
+		{&Config{Addr: "localhost:2003", Jitter: -time.Second}, "invalid config: negative jitter -1s"},
+		{&Config{Addr: "localhost:2003", MaxBatchBytes: -1}, "invalid config: negative batch size"},
+		{&Config{Addr: "localhost:2003", Prefix: "foo bar"}, `invalid config: invalid prefix "foo bar": contains the character ' '`},
+		{&Config{Addr: "localhost:2003", Prefix: "foo..bar"}, `invalid config: invalid prefix "foo..bar": contains an empty node`},
+		{&Config{Addr: "localhost:2003", StatsPrefix: "mgr."}, `invalid config: invalid stats prefix "mgr.": contains an empty node`},
+		{&Config{Addr: "localhost:2003", Prefix: "foo.bar-baz_1"}, ""},
+		{&Config{Addr: "127.0.0.1:2003"}, ""},
+	}
+
+	for _, tc := range testCases {
+		err := tc.config.Validate()
+		if tc.err == "" {
+			require.Nil(t, err)
+			continue
+		}
+
+		require.NotNil(t, err)
+		require.True(t, errors.Is(err, ErrInvalidConfig))
+		require.Equal(t, tc.err, err.Error())
+	}
+}
+
+func TestExportInvalidConfig(t *testing.T) {
+	config := &Config{Interval: time.Second}
+
+	err := Export(config)
+	require.True(t, errors.Is(err, ErrInvalidConfig))
+	require.Nil(t, config.Logger)
+}
+
+func TestWithDefaults(t *testing.T) {
+	config := &Config{Addr: "localhost:2003"}
+
+	res := config.withDefaults()
+	require.Equal(t, DefaultInterval, res.Interval)
+	require.NotNil(t, res.Logger)
+
+	require.Equal(t, time.Duration(0), config.Interval)
+	require.Nil(t, config.Logger)
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	expected := &Config{
+		Addr:          "graphite.example.com:2003",
+		Prefix:        "prod.api",
+		Interval:      10 * time.Second,
+		Align:         true,
+		MaxBatchLines: 1000,
+	}
+
+	for _, path := range []string{"testdata/config.json", "testdata/config.toml", "testdata/config.yaml"} {
+		config, err := LoadConfigFile(path)
+		require.Nil(t, err, path)
+		require.Equal(t, expected, config, path)
+	}
+}
+
+func TestLoadConfigFileEmptyValues(t *testing.T) {
+	config, err := LoadConfigFile("testdata/empty.yaml")
+	require.Nil(t, err)
+	require.Equal(t, &Config{Addr: "localhost:2003"}, config)
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	_, err := LoadConfigFile("testdata/unknown.toml")
+	require.Equal(t, `testdata/unknown.toml: unknown setting "adress"`, err.Error())
+
+	_, err = LoadConfigFile("testdata/nested.yaml")
+	require.Equal(t, "testdata/nested.yaml: line 1: nested settings are not supported", err.Error())
+
+	_, err = LoadConfigFile("testdata/config.ini")
+	require.NotNil(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("MGR_ADDR", "localhost:2003")
+	t.Setenv("MGR_INTERVAL", "1m")
+	t.Setenv("MGR_STATS_PREFIX", "mgr")
+	t.Setenv("MGR_UNRELATED", "foo")
+
+	config, err := ConfigFromEnv()
+	require.Nil(t, err)
+	require.Equal(t, &Config{Addr: "localhost:2003", Interval: time.Minute, StatsPrefix: "mgr"}, config)
+
+	t.Setenv("MGR_INTERVAL", "soon")
+	_, err = ConfigFromEnv()
+	require.Equal(t, `environment variable MGR_INTERVAL: invalid interval "soon": time: invalid duration "soon"`, err.Error())
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	t.Setenv("MGR_CONFIG", "testdata/config.yaml")
+	t.Setenv("MGR_PREFIX", "staging.api")
+
+	config, err := LoadConfig("")
+	require.Nil(t, err)
+	require.Equal(t, "graphite.example.com:2003", config.Addr)
+	require.Equal(t, "staging.api", config.Prefix)
+	require.Equal(t, 10*time.Second, config.Interval)
+
+	config, err = LoadConfig("testdata/config.json")
+	require.Nil(t, err)
+	require.Equal(t, "staging.api", config.Prefix)
+	require.Equal(t, 1000, config.MaxBatchLines)
+}
@@ -0,0 +1,239 @@
+package mgr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigEnvPrefix is the prefix of the environment variables read by LoadConfig,
+// for example MGR_ADDR sets the addr setting.
+const ConfigEnvPrefix = "MGR_"
+
+// ConfigFileEnv is the environment variable holding the path of the configuration file
+// read by LoadConfig when none is given.
+const ConfigFileEnv = ConfigEnvPrefix + "CONFIG"
+
+// configSettings maps the name of each setting, as used in configuration files and
+// (upper cased) in environment variables, to a function applying it to a Config.
+var configSettings = map[string]func(c *Config, value string) error{
+	"addr":            func(c *Config, v string) error { c.Addr = v; return nil },
+	"prefix":          func(c *Config, v string) error { c.Prefix = v; return nil },
+	"stats_prefix":    func(c *Config, v string) error { c.StatsPrefix = v; return nil },
+	"interval":        func(c *Config, v string) (err error) { c.Interval, err = time.ParseDuration(v); return },
+	"jitter":          func(c *Config, v string) (err error) { c.Jitter, err = time.ParseDuration(v); return },
+	"align":           func(c *Config, v string) (err error) { c.Align, err = strconv.ParseBool(v); return },
+	"max_batch_lines": func(c *Config, v string) (err error) { c.MaxBatchLines, err = strconv.Atoi(v); return },
+	"max_batch_bytes": func(c *Config, v string) (err error) { c.MaxBatchBytes, err = strconv.Atoi(v); return },
+}
+
+func applySetting(c *Config, name, value string) error {
+	fn, ok := configSettings[name]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if err := fn(c, value); err != nil {
+		return fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+	return nil
+}
+
+// LoadConfig builds a Config from the configuration file at `path` and from the environment.
+//
+// If `path` is empty, the file named by the MGR_CONFIG environment variable is read, if set.
+// The environment variables (MGR_ADDR, MGR_PREFIX, MGR_INTERVAL, MGR_ALIGN, MGR_JITTER,
+// MGR_MAX_BATCH_LINES, MGR_MAX_BATCH_BYTES and MGR_STATS_PREFIX) take precedence over the file.
+//
+// The returned Config is not validated, use Config.Validate.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+
+	config := new(Config)
+	if path != "" {
+		var err error
+		if config, err = LoadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(config, os.Environ()); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// ConfigFromEnv builds a Config from the environment variables only. See LoadConfig.
+func ConfigFromEnv() (*Config, error) {
+	config := new(Config)
+	if err := applyEnv(config, os.Environ()); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func applyEnv(config *Config, environ []string) error {
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, ConfigEnvPrefix) {
+			continue
+		}
+
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+
+		key, value := kv[:i], kv[i+1:]
+		if key == ConfigFileEnv {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(key, ConfigEnvPrefix))
+		if _, ok := configSettings[name]; !ok {
+			continue
+		}
+		if err := applySetting(config, name, value); err != nil {
+			return fmt.Errorf("environment variable %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadConfigFile builds a Config from the configuration file at `path`.
+//
+// The format is chosen from the extension: .json, .toml, .yaml or .yml.
+// Only top-level settings are supported, named like the environment variables without
+// the MGR_ prefix and in lower case, for example:
+//
+//	addr = "localhost:2003"
+//	prefix = "prod.api"
+//	interval = "10s"
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings map[string]string
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		settings, err = parseJSONSettings(data)
+	case ".toml":
+		settings, err = parseFlatSettings(data, "=")
+	case ".yaml", ".yml":
+		settings, err = parseFlatSettings(data, ":")
+	default:
+		err = fmt.Errorf("unknown configuration file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	config := new(Config)
+	for name, value := range settings {
+		if err := applySetting(config, name, value); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return config, nil
+}
+
+func parseJSONSettings(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			res[name] = v
+		case json.Number:
+			res[name] = v.String()
+		case bool:
+			res[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("setting %q is not a string, number or boolean", name)
+		}
+	}
+
+	return res, nil
+}
+
+// parseFlatSettings parses the subset of TOML (with sep "=") and YAML (with sep ":")
+// made of top-level scalar settings, one per line, and comments.
+func parseFlatSettings(data []byte, sep string) (map[string]string, error) {
+	res := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+
+		i := strings.Index(line, sep)
+		if i <= 0 || line[0] == '[' {
+			return nil, fmt.Errorf("line %d: expected a %q separated setting", n, sep)
+		}
+
+		name := strings.TrimSpace(line[:i])
+		raw := strings.TrimSpace(line[i+len(sep):])
+
+		// In YAML, a missing value starts a nested mapping, unlike an empty quoted string.
+		if sep == ":" && (raw == "" || raw[0] == '#') {
+			return nil, fmt.Errorf("line %d: nested settings are not supported", n)
+		}
+
+		value, err := parseScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		res[name] = value
+	}
+
+	return res, scanner.Err()
+}
+
+// parseScalar parses a quoted or bare scalar, stripping a trailing comment.
+func parseScalar(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	switch s[0] {
+	case '"':
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return strconv.Unquote(quoted)
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return s[1 : end+1], nil
+	}
+
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return s, nil
+}
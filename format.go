@@ -0,0 +1,108 @@
+package mgr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format encodes samples in the wire format of a backend.
+type Format interface {
+	// Append appends the line encoding the value `v` of the metric `key` at time `ts` to `dst`.
+	Append(dst []byte, key string, v Value, ts time.Time) []byte
+}
+
+// Graphite is the Graphite plaintext protocol with timestamps in seconds, as expected by carbon.
+var Graphite Format = GraphiteFormat{Precision: time.Second}
+
+// GraphiteFormat is the Graphite plaintext protocol: one "<key> <value> <timestamp>" line per sample.
+type GraphiteFormat struct {
+	// Precision is the precision of the timestamps, which are truncated to it.
+	// When finer than a second, timestamps are written as fractional seconds,
+	// which some Graphite compatible backends like VictoriaMetrics accept.
+	// Defaults to a second.
+	Precision time.Duration
+}
+
+func (f GraphiteFormat) Append(dst []byte, key string, v Value, ts time.Time) []byte {
+	dst = append(dst, key...)
+	dst = append(dst, ' ')
+	dst = append(dst, v.String()...)
+	dst = append(dst, ' ')
+
+	precision := f.Precision
+	if precision <= 0 {
+		precision = time.Second
+	}
+
+	if precision >= time.Second {
+		dst = strconv.AppendInt(dst, ts.Truncate(precision).Unix(), 10)
+	} else {
+		dst = appendFractionalSeconds(dst, ts, precision)
+	}
+
+	return append(dst, '\n')
+}
+
+// appendFractionalSeconds appends the unix time `ts`, truncated to `precision`, as fractional seconds.
+// The seconds and the fraction are formatted separately to not lose digits to a float64,
+// with as many decimals as needed to represent any multiple of `precision`, for example 2 for 250ms.
+func appendFractionalSeconds(dst []byte, ts time.Time, precision time.Duration) []byte {
+	sec, nsec := ts.Unix(), int64(ts.Nanosecond())
+	nsec -= nsec % int64(precision)
+
+	digits, unit := 9, int64(1)
+	for int64(precision)%(unit*10) == 0 {
+		digits--
+		unit *= 10
+	}
+
+	dst = strconv.AppendInt(dst, sec, 10)
+	dst = append(dst, '.')
+
+	frac := strconv.FormatInt(nsec/unit, 10)
+	for i := len(frac); i < digits; i++ {
+		dst = append(dst, '0')
+	}
+
+	return append(dst, frac...)
+}
+
+// InfluxFormat is the InfluxDB line protocol: one "<key> value=<value> <timestamp>" line per sample.
+type InfluxFormat struct {
+	// Precision is the precision of the timestamps, which must match the precision parameter
+	// of the server: time.Nanosecond, time.Microsecond, time.Millisecond or time.Second.
+	// Defaults to a nanosecond.
+	Precision time.Duration
+}
+
+var influxKeyReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+func (f InfluxFormat) Append(dst []byte, key string, v Value, ts time.Time) []byte {
+	dst = append(dst, influxKeyReplacer.Replace(key)...)
+	dst = append(dst, " value="...)
+
+	switch v.Type() {
+	case TypeInt64:
+		dst = strconv.AppendInt(dst, v.Int64(), 10)
+		dst = append(dst, 'i')
+	case TypeUint64:
+		dst = strconv.AppendUint(dst, v.Uint64(), 10)
+		dst = append(dst, 'u')
+	case TypeFloat64:
+		dst = strconv.AppendFloat(dst, v.Float64(), 'g', -1, 64)
+	case TypeBool:
+		dst = strconv.AppendBool(dst, v.Bool())
+	default:
+		dst = strconv.AppendQuote(dst, v.String())
+	}
+	dst = append(dst, ' ')
+
+	precision := f.Precision
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+	dst = strconv.AppendInt(dst, ts.UnixNano()/int64(precision), 10)
+
+	return append(dst, '\n')
+}
@@ -0,0 +1,64 @@
+package mgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphiteFormat(t *testing.T) {
+	ts := time.Unix(1500, 123456789)
+
+	line := Graphite.Append(nil, "foo.bar", Int64Value(10), ts)
+	require.Equal(t, "foo.bar 10 1500\n", string(line))
+
+	line = GraphiteFormat{}.Append(nil, "foo.bar", Float64Value(0.5), ts)
+	require.Equal(t, "foo.bar 0.5 1500\n", string(line))
+
+	line = GraphiteFormat{Precision: time.Millisecond}.Append(nil, "foo.bar", Int64Value(10), ts)
+	require.Equal(t, "foo.bar 10 1500.123\n", string(line))
+
+	line = GraphiteFormat{Precision: time.Nanosecond}.Append(nil, "foo.bar", Int64Value(10), ts)
+	require.Equal(t, "foo.bar 10 1500.123456789\n", string(line))
+
+	line = GraphiteFormat{Precision: 250 * time.Millisecond}.Append(nil, "foo.bar", Int64Value(10), time.Unix(1700000000, 750000000))
+	require.Equal(t, "foo.bar 10 1700000000.75\n", string(line))
+
+	line = GraphiteFormat{Precision: 250 * time.Millisecond}.Append(nil, "foo.bar", Int64Value(10), time.Unix(1700000000, 249999999))
+	require.Equal(t, "foo.bar 10 1700000000.00\n", string(line))
+
+	line = GraphiteFormat{Precision: time.Millisecond}.Append(nil, "foo.bar", Int64Value(10), time.Unix(1500, 5000000))
+	require.Equal(t, "foo.bar 10 1500.005\n", string(line))
+
+	line = GraphiteFormat{Precision: time.Minute}.Append(nil, "foo.bar", Int64Value(10), ts)
+	require.Equal(t, "foo.bar 10 1500\n", string(line))
+}
+
+func TestInfluxFormat(t *testing.T) {
+	ts := time.Unix(1500, 123456789)
+
+	line := InfluxFormat{}.Append(nil, "foo.bar", Int64Value(10), ts)
+	require.Equal(t, "foo.bar value=10i 1500123456789\n", string(line))
+
+	line = InfluxFormat{Precision: time.Millisecond}.Append(nil, "foo bar,baz", Float64Value(0.5), ts)
+	require.Equal(t, `foo\ bar\,baz value=0.5 1500123`+"\n", string(line))
+
+	line = InfluxFormat{Precision: time.Second}.Append(nil, "foo", Uint64Value(3), ts)
+	require.Equal(t, "foo value=3u 1500\n", string(line))
+
+	line = InfluxFormat{Precision: time.Second}.Append(nil, "foo", BoolValue(true), ts)
+	require.Equal(t, "foo value=true 1500\n", string(line))
+}
+
+func TestReportFormat(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	NewInt("foobar").Set(10)
+
+	clock := fakeClock(100)
+	err := report(&Config{Clock: clock, Format: InfluxFormat{Precision: time.Millisecond}})
+	require.Nil(t, err)
+	require.Equal(t, "foobar value=10i 100000\n", buf.String())
+}
@@ -0,0 +1,67 @@
+package mgr
+
+// GaugeFunc is a float variable computed by calling a function that satisfies the Var interface.
+//
+// The function is called once per export, if it panics the panic is recovered and
+// logged with Config.Logger and no value is reported.
+type GaugeFunc struct {
+	key string
+	fn  func() float64
+}
+
+// NewGaugeFunc creates a GaugeFunc and publishes it.
+func NewGaugeFunc(name string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{key: name, fn: fn}
+	Publish(g)
+
+	return g
+}
+
+// Items calls the function and returns its result in a 1-size KeyValue slice.
+func (g *GaugeFunc) Items() []KeyValue { return formatSamples(g.Samples()) }
+
+// Samples calls the function and returns its result in a 1-size Sample slice.
+func (g *GaugeFunc) Samples() []Sample {
+	return []Sample{{
+		Key:   g.key,
+		Value: Float64Value(g.fn()),
+		Kind:  KindGauge,
+	}}
+}
+
+// IntFunc is a 64-bit integer variable computed by calling a function that satisfies the Var interface.
+//
+// The function is called once per export, if it panics the panic is recovered and
+// logged with Config.Logger and no value is reported.
+type IntFunc struct {
+	key string
+	fn  func() int64
+}
+
+// NewIntFunc creates an IntFunc and publishes it.
+func NewIntFunc(name string, fn func() int64) *IntFunc {
+	i := &IntFunc{key: name, fn: fn}
+	Publish(i)
+
+	return i
+}
+
+// Items calls the function and returns its result in a 1-size KeyValue slice.
+func (i *IntFunc) Items() []KeyValue { return formatSamples(i.Samples()) }
+
+// Samples calls the function and returns its result in a 1-size Sample slice.
+func (i *IntFunc) Samples() []Sample {
+	return []Sample{{
+		Key:   i.key,
+		Value: Int64Value(i.fn()),
+		Kind:  KindGauge,
+	}}
+}
+
+var (
+	_ Var = (*GaugeFunc)(nil)
+	_ Var = (*IntFunc)(nil)
+
+	_ Sampler = (*GaugeFunc)(nil)
+	_ Sampler = (*IntFunc)(nil)
+)
@@ -0,0 +1,80 @@
+package mgr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncs(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	calls := 0
+	NewIntFunc("foobar.int", func() int64 {
+		calls++
+		return 42
+	})
+	NewGaugeFunc("foobar.gauge", func() float64 { return 0.25 })
+
+	config := &Config{Clock: fakeClock(100)}
+
+	err := report(config)
+	require.Nil(t, err)
+	require.Equal(t, "foobar.int 42 100\nfoobar.gauge 0.25 100\n", buf.String())
+	require.Equal(t, 1, calls)
+}
+
+func TestFuncPanic(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	NewGaugeFunc("foobar.panic", func() float64 { panic("boom") })
+	NewIntFunc("foobar.int", func() int64 { return 42 })
+
+	var logs []string
+	config := &Config{
+		Clock: fakeClock(100),
+		Logger: func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		},
+	}
+
+	err := report(config)
+	require.Nil(t, err)
+	require.Equal(t, "foobar.int 42 100\n", buf.String())
+	require.Equal(t, []string{"unable to get items of variable. panic=boom"}, logs)
+}
+
+func TestFuncPanicInMap(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	var i Int
+	i.Set(3)
+
+	var nested Map
+	nested.Init().Set("panic", Func(func() []KeyValue { panic("bang") }))
+
+	m := NewMap("foobar")
+	m.Set("hits", &i)
+	m.Set("gauge", &GaugeFunc{fn: func() float64 { panic("boom") }})
+	m.Set("nested", &nested)
+
+	var logs []string
+	config := &Config{
+		Clock: fakeClock(100),
+		Logger: func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		},
+	}
+
+	err := report(config)
+	require.Nil(t, err)
+	require.Equal(t, "foobar.hits 3 100\n", buf.String())
+	require.Equal(t, []string{
+		"unable to get items of variable. panic=boom",
+		"unable to get items of variable. panic=bang",
+	}, logs)
+}
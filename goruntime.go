@@ -0,0 +1,110 @@
+package mgr
+
+import (
+	"math"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+)
+
+// GoRuntime is a variable reporting the state of the Go runtime that satisfies the Var interface.
+//
+// It reports the number of goroutines, OS threads, cgo calls and GOMAXPROCS, along with the
+// distribution of every GC pause which occurred since the previous export:
+// their count, total, max and percentiles in nanoseconds.
+// Note that, like MemStats, it calls runtime.ReadMemStats which stops the world.
+type GoRuntime struct {
+	key string
+
+	mu          sync.Mutex
+	percentiles []float64
+	stats       runtime.MemStats
+	lastNumGC   uint32
+	lastPauseNs uint64
+}
+
+// NewGoRuntime creates a GoRuntime reporting the given GC pause percentiles and publishes it.
+// If no percentile is given, DefaultRuntimePercentiles are reported.
+func NewGoRuntime(name string, percentiles ...float64) *GoRuntime {
+	if len(percentiles) == 0 {
+		percentiles = DefaultRuntimePercentiles
+	}
+	checkPercentiles(percentiles)
+
+	g := &GoRuntime{
+		key:         name,
+		percentiles: append([]float64(nil), percentiles...),
+	}
+	Publish(g)
+
+	return g
+}
+
+func (g *GoRuntime) Items() []KeyValue { return formatSamples(g.Samples()) }
+
+// Samples reads the state of the runtime.
+func (g *GoRuntime) Samples() []Sample {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	runtime.ReadMemStats(&g.stats)
+
+	pauses := pausesSince(&g.stats, g.lastNumGC)
+	count := g.stats.NumGC - g.lastNumGC
+	total := g.stats.PauseTotalNs - g.lastPauseNs
+	g.lastNumGC = g.stats.NumGC
+	g.lastPauseNs = g.stats.PauseTotalNs
+
+	gauge := func(name string, v Value) Sample {
+		return Sample{Key: JoinKey(g.key, name), Value: v, Kind: KindGauge}
+	}
+	counter := func(name string, v Value) Sample {
+		return Sample{Key: JoinKey(g.key, name), Value: v, Kind: KindCounter}
+	}
+
+	res := []Sample{
+		gauge("goroutines", Int64Value(int64(runtime.NumGoroutine()))),
+		gauge("threads", Int64Value(int64(pprof.Lookup("threadcreate").Count()))),
+		counter("cgo_calls", Int64Value(runtime.NumCgoCall())),
+		gauge("gomaxprocs", Int64Value(int64(runtime.GOMAXPROCS(0)))),
+		gauge("gc.pause.count", Uint64Value(uint64(count))),
+		gauge("gc.pause.total_ns", Uint64Value(total)),
+	}
+
+	if len(pauses) == 0 {
+		return res
+	}
+
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i] < pauses[j] })
+
+	res = append(res, gauge("gc.pause.max_ns", Uint64Value(pauses[len(pauses)-1])))
+	for _, p := range g.percentiles {
+		n := int(math.Ceil(p / 100 * float64(len(pauses)-1)))
+		res = append(res, gauge("gc.pause."+percentileName(p)+"_ns", Uint64Value(pauses[n])))
+	}
+
+	return res
+}
+
+// pausesSince returns the GC pauses which occurred after the GC number `lastNumGC`.
+// Only the 256 most recent pauses are kept by the runtime, older ones are lost.
+func pausesSince(stats *runtime.MemStats, lastNumGC uint32) []uint64 {
+	n := stats.NumGC - lastNumGC
+	if n > uint32(len(stats.PauseNs)) {
+		n = uint32(len(stats.PauseNs))
+	}
+
+	res := make([]uint64, n)
+	for i := uint32(0); i < n; i++ {
+		// The most recent pause is at PauseNs[(NumGC+255)%256].
+		res[i] = stats.PauseNs[(stats.NumGC-i+255)%256]
+	}
+
+	return res
+}
+
+var (
+	_ Var     = (*GoRuntime)(nil)
+	_ Sampler = (*GoRuntime)(nil)
+)
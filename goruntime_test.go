@@ -0,0 +1,46 @@
+package mgr
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPausesSince(t *testing.T) {
+	var stats runtime.MemStats
+	for i := range stats.PauseNs {
+		stats.PauseNs[i] = uint64(i)
+	}
+
+	stats.NumGC = 3
+	require.Equal(t, []uint64{2, 1, 0}, pausesSince(&stats, 0))
+	require.Equal(t, []uint64{2}, pausesSince(&stats, 2))
+	require.Equal(t, []uint64{}, pausesSince(&stats, 3))
+
+	// The ring buffer wrapped around.
+	stats.NumGC = 258
+	require.Equal(t, []uint64{1, 0, 255}, pausesSince(&stats, 255))
+	require.Len(t, pausesSince(&stats, 0), 256)
+}
+
+func TestGoRuntime(t *testing.T) {
+	g := NewGoRuntime("go", 50, 99)
+	g.Samples()
+
+	runtime.GC()
+	runtime.GC()
+
+	values := make(map[string]Value)
+	for _, s := range g.Samples() {
+		values[s.Key] = s.Value
+	}
+
+	require.True(t, values["go.goroutines"].Int64() > 0)
+	require.True(t, values["go.threads"].Int64() > 0)
+	require.Equal(t, int64(runtime.GOMAXPROCS(0)), values["go.gomaxprocs"].Int64())
+	require.True(t, values["go.gc.pause.count"].Uint64() >= 2)
+	require.Contains(t, values, "go.gc.pause.max_ns")
+	require.Contains(t, values, "go.gc.pause.p50_ns")
+	require.Contains(t, values, "go.gc.pause.p99_ns")
+}
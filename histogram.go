@@ -1,28 +1,53 @@
 package mgr
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// HistogramStat is a set of statistics reported by a Histogram.
+type HistogramStat uint
+
+const (
+	StatMean HistogramStat = 1 << iota
+	StatMax
+	StatMin
+	StatStddev
+	// StatCount is the total number of values recorded since the histogram was created.
+	StatCount
+	// StatSum is the sum of all values recorded since the histogram was created.
+	StatSum
+
+	// DefaultHistogramStats are the statistics reported by a Histogram unless configured otherwise.
+	DefaultHistogramStats = StatMean | StatMax | StatMin | StatStddev
+)
+
+// DefaultPercentiles are the percentiles reported by a Histogram unless configured otherwise.
+var DefaultPercentiles = []float64{50, 75, 90, 95, 98, 99, 99.9, 99.99}
+
 type Histogram struct {
 	key    string
 	Buffer []int64
 
-	mu       sync.Mutex
-	counter  int64
-	snapshot []int64
+	mu          sync.Mutex
+	counter     int64
+	sum         int64
+	stats       HistogramStat
+	percentiles []float64
+
+	snapshot      []int64
+	snapshotCount int64
+	snapshotSum   int64
 }
 
 func NewHistogram(name string, bufferSize int) *Histogram {
-	h := &Histogram{
-		key:      name,
-		Buffer:   make([]int64, bufferSize),
-		snapshot: make([]int64, bufferSize),
-	}
+	h := &Histogram{key: name}
+	h.Init(bufferSize)
 	Publish(h)
 
 	return h
@@ -31,18 +56,39 @@ func NewHistogram(name string, bufferSize int) *Histogram {
 func (h *Histogram) Init(bufferSize int) *Histogram {
 	h.Buffer = make([]int64, bufferSize)
 	h.snapshot = make([]int64, bufferSize)
+	h.stats = DefaultHistogramStats
+	h.percentiles = DefaultPercentiles
 
 	return h
 }
 
-func (h *Histogram) takeSnapshot() {
+// Configure sets the statistics and percentiles reported by the histogram.
+// Each percentile p is reported as a series named after it, for example 99.9 is reported as p999.
+// It panics if a percentile is not between 0 and 100 or if two percentiles have the same name, like 99.9 and 9.99.
+func (h *Histogram) Configure(stats HistogramStat, percentiles ...float64) *Histogram {
+	checkPercentiles(percentiles)
+
+	h.mu.Lock()
+	h.stats = stats
+	h.percentiles = append([]float64(nil), percentiles...)
+	h.mu.Unlock()
+
+	return h
+}
+
+func (h *Histogram) takeSnapshot() (HistogramStat, []float64) {
 	h.mu.Lock()
 
 	// TODO(vincent): maybe we'll need to have multiple snapshots to protect
 	// from concurrent Items() calls.
 	copy(h.snapshot, h.Buffer)
+	h.snapshotCount = h.counter
+	h.snapshotSum = h.sum
+	stats, percentiles := h.stats, h.percentiles
 
 	h.mu.Unlock()
+
+	return stats, percentiles
 }
 
 func (h *Histogram) mean() float64 {
@@ -111,12 +157,35 @@ func (h *Histogram) percentile(p float64) int64 {
 	return h.snapshot[n]
 }
 
+// percentileName returns the series name of the percentile p, for example p999 for 99.9.
+func percentileName(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "", -1)
+}
+
+// checkPercentiles panics if a percentile is not between 0 and 100 or if two percentiles would be
+// reported under the same series name, for example 99.9 and 9.99 which are both named p999.
+func checkPercentiles(percentiles []float64) {
+	names := make(map[string]float64, len(percentiles))
+	for _, p := range percentiles {
+		if !(p >= 0 && p <= 100) {
+			panic(fmt.Sprintf("mgr: percentile %v is not between 0 and 100", p))
+		}
+
+		name := percentileName(p)
+		if q, ok := names[name]; ok {
+			panic(fmt.Sprintf("mgr: percentiles %v and %v are both named %s", q, p, name))
+		}
+		names[name] = p
+	}
+}
+
 func (h *Histogram) Record(val int64) {
 	h.mu.Lock()
 
 	idx := int(h.counter % int64(len(h.Buffer)))
 	h.Buffer[idx] = val
 	h.counter++
+	h.sum += val
 
 	h.mu.Unlock()
 }
@@ -125,25 +194,49 @@ func (h *Histogram) RecordSince(t time.Time) {
 	h.Record(int64(time.Since(t)))
 }
 
-func (h *Histogram) Items() []KeyValue {
-	h.takeSnapshot()
+func (h *Histogram) Items() []KeyValue { return formatSamples(h.Samples()) }
 
-	n := func(s string) string { return h.key + "." + s }
-	f := func(f float64) string { return strconv.FormatFloat(f, 'g', 5, 64) }
-	i := func(i int64) string { return strconv.FormatInt(i, 10) }
+// Samples returns the configured statistics and percentiles of the recorded values.
+func (h *Histogram) Samples() []Sample {
+	return h.samples(h.takeSnapshot())
+}
 
-	return []KeyValue{
-		{n("mean"), f(h.mean())},
-		{n("max"), i(h.max())},
-		{n("min"), i(h.min())},
-		{n("stddev"), f(h.stddev())},
-		{n("p50"), i(h.percentile(50))},
-		{n("p75"), i(h.percentile(75))},
-		{n("p90"), i(h.percentile(90))},
-		{n("p95"), i(h.percentile(95))},
-		{n("p98"), i(h.percentile(98))},
-		{n("p99"), i(h.percentile(99))},
-		{n("p999"), i(h.percentile(99.9))},
-		{n("p9999"), i(h.percentile(99.99))},
+// samples computes the statistics of the current snapshot.
+func (h *Histogram) samples(stats HistogramStat, percentiles []float64) []Sample {
+	gauge := func(name string, v Value) Sample {
+		return Sample{Key: JoinKey(h.key, name), Value: v, Kind: KindGauge}
+	}
+	counter := func(name string, v Value) Sample {
+		return Sample{Key: JoinKey(h.key, name), Value: v, Kind: KindCounter}
+	}
+
+	var res []Sample
+	if stats&StatMean != 0 {
+		res = append(res, gauge("mean", roundedFloat64Value(h.mean())))
+	}
+	if stats&StatMax != 0 {
+		res = append(res, gauge("max", Int64Value(h.max())))
 	}
+	if stats&StatMin != 0 {
+		res = append(res, gauge("min", Int64Value(h.min())))
+	}
+	if stats&StatStddev != 0 {
+		res = append(res, gauge("stddev", roundedFloat64Value(h.stddev())))
+	}
+	if stats&StatCount != 0 {
+		res = append(res, counter("count", Int64Value(h.snapshotCount)))
+	}
+	if stats&StatSum != 0 {
+		res = append(res, counter("sum", Int64Value(h.snapshotSum)))
+	}
+	for _, p := range percentiles {
+		res = append(res, gauge(percentileName(p), Int64Value(h.percentile(p))))
+	}
+
+	return res
 }
+
+var (
+	_ Var     = (*Histogram)(nil)
+	_ Sampler = (*Histogram)(nil)
+)
@@ -2,6 +2,7 @@ package mgr
 
 import (
 	"log"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -20,6 +21,17 @@ func TestHistogramMean(t *testing.T) {
 	require.Equal(t, 2.0, h.mean())
 }
 
+func TestHistogramMeanFormatting(t *testing.T) {
+	h := NewHistogram("foobar", 3).Configure(StatMean)
+
+	h.Record(1)
+	h.Record(1)
+	h.Record(2)
+
+	require.Equal(t, []KeyValue{{"foobar.mean", "1.3333"}}, h.Items())
+	require.Equal(t, 4.0/3, h.Samples()[0].Value.Float64())
+}
+
 func TestHistogramMax(t *testing.T) {
 	h := NewHistogram("foobar", 10000)
 
@@ -94,3 +106,56 @@ func TestHistogram(t *testing.T) {
 	log.Printf("items: %v", items)
 	// TODO(vincent): test this somehow
 }
+
+func TestHistogramDefaultItems(t *testing.T) {
+	h := NewHistogram("foobar", 8)
+	h.Record(1)
+
+	var keys []string
+	for _, item := range h.Items() {
+		keys = append(keys, item.Key)
+	}
+
+	require.Equal(t, []string{
+		"foobar.mean", "foobar.max", "foobar.min", "foobar.stddev",
+		"foobar.p50", "foobar.p75", "foobar.p90", "foobar.p95",
+		"foobar.p98", "foobar.p99", "foobar.p999", "foobar.p9999",
+	}, keys)
+}
+
+func TestHistogramConfigure(t *testing.T) {
+	h := NewHistogram("foobar", 4).Configure(StatCount|StatSum, 50, 99)
+
+	for i := int64(1); i <= 6; i++ {
+		h.Record(i)
+	}
+
+	require.Equal(t, []KeyValue{
+		{"foobar.count", "6"},
+		{"foobar.sum", "21"},
+		{"foobar.p50", "5"},
+		{"foobar.p99", "6"},
+	}, h.Items())
+}
+
+func TestPercentileName(t *testing.T) {
+	require.Equal(t, "p50", percentileName(50))
+	require.Equal(t, "p999", percentileName(99.9))
+	require.Equal(t, "p9999", percentileName(99.99))
+}
+
+func TestHistogramConfigureClashingPercentiles(t *testing.T) {
+	var h Histogram
+	h.Init(4)
+
+	require.PanicsWithValue(t, "mgr: percentiles 99.9 and 9.99 are both named p999", func() {
+		h.Configure(DefaultHistogramStats, 50, 99.9, 9.99)
+	})
+	require.Panics(t, func() { NewTDigest("foobar", 0, 99.9, 9.99) })
+
+	require.PanicsWithValue(t, "mgr: percentile 150 is not between 0 and 100", func() {
+		h.Configure(StatMax, 150)
+	})
+	require.Panics(t, func() { h.Configure(StatMax, -10) })
+	require.Panics(t, func() { h.Configure(StatMax, math.NaN()) })
+}
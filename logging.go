@@ -0,0 +1,95 @@
+package mgr
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+)
+
+// persistentFailures is the number of consecutive failed exports after which
+// failures are logged as errors rather than warnings.
+const persistentFailures = 3
+
+// exportError is an error annotated with the step of the export which failed.
+type exportError struct {
+	kind string
+	err  error
+}
+
+func (e *exportError) Error() string { return e.err.Error() }
+
+func (e *exportError) Unwrap() error { return e.err }
+
+// errorKind returns the kind of the export error `err`: dial, write, timeout or unknown.
+func errorKind(err error) string {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return "timeout"
+	}
+
+	var eerr *exportError
+	if errors.As(err, &eerr) {
+		return eerr.kind
+	}
+
+	return "unknown"
+}
+
+// logExport logs the outcome of an export. `attempt` is the number of consecutive failed exports.
+func logExport(config *Config, b *batch, attempt int64, err error) {
+	if config == nil {
+		return
+	}
+
+	if config.Slog == nil {
+		if err != nil && config.Logger != nil {
+			config.Logger("unable to report data. err=%v", err)
+		}
+		return
+	}
+
+	if err == nil {
+		config.Slog.Debug("reported data",
+			slog.String("addr", config.Addr),
+			slog.Int64("bytes_written", b.bytesSent),
+			slog.Int64("lines_written", b.linesSent),
+		)
+		return
+	}
+
+	level := slog.LevelWarn
+	if attempt >= persistentFailures {
+		level = slog.LevelError
+	}
+
+	config.Slog.LogAttrs(context.Background(), level, "unable to report data",
+		slog.String("addr", config.Addr),
+		slog.Int64("attempt", attempt),
+		slog.Int64("bytes_written", b.bytesSent),
+		slog.Int64("lines_dropped", b.droppedLines),
+		slog.String("error_kind", errorKind(err)),
+		slog.Any("error", err),
+	)
+}
+
+func logReconnect(config *Config) {
+	if config == nil || config.Slog == nil {
+		return
+	}
+
+	config.Slog.Info("reconnected to the Graphite server", slog.String("addr", config.Addr))
+}
+
+func logPanic(config *Config, r interface{}) {
+	switch {
+	case config == nil:
+	case config.Slog != nil:
+		config.Slog.Error("unable to get items of variable",
+			slog.String("error_kind", "panic"),
+			slog.Any("panic", r),
+		)
+	case config.Logger != nil:
+		config.Logger("unable to get items of variable. panic=%v", r)
+	}
+}
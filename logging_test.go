@@ -0,0 +1,75 @@
+package mgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSlog(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) (records []map[string]interface{}) {
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.Nil(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return
+}
+
+func TestSlogFailures(t *testing.T) {
+	_, fn := reset()
+	defer fn()
+
+	dialFn = func(_ *Config) (io.Writer, error) { return nil, errors.New("connection refused") }
+
+	var logs bytes.Buffer
+	config := &Config{Addr: "localhost:2003", Clock: fakeClock(100), Slog: newTestSlog(&logs)}
+
+	for i := 0; i < persistentFailures; i++ {
+		require.NotNil(t, report(config))
+	}
+
+	records := decodeRecords(t, &logs)
+	require.Len(t, records, persistentFailures)
+
+	for i, record := range records {
+		require.Equal(t, "unable to report data", record["msg"])
+		require.Equal(t, "localhost:2003", record["addr"])
+		require.Equal(t, float64(i+1), record["attempt"])
+		require.Equal(t, "dial", record["error_kind"])
+		require.Equal(t, "connection refused", record["error"])
+	}
+	require.Equal(t, "WARN", records[0]["level"])
+	require.Equal(t, "ERROR", records[persistentFailures-1]["level"])
+}
+
+func TestSlogSuccess(t *testing.T) {
+	_, fn := reset()
+	defer fn()
+
+	NewInt("foobar")
+
+	var logs bytes.Buffer
+	config := &Config{Addr: "localhost:2003", Clock: fakeClock(100), Slog: newTestSlog(&logs)}
+
+	require.Nil(t, report(config))
+
+	records := decodeRecords(t, &logs)
+	require.Len(t, records, 1)
+	require.Equal(t, "DEBUG", records[0]["level"])
+	require.Equal(t, float64(len("foobar 0 100\n")), records[0]["bytes_written"])
+}
+
+func TestErrorKind(t *testing.T) {
+	require.Equal(t, "write", errorKind(&exportError{kind: "write", err: errors.New("broken pipe")}))
+	require.Equal(t, "unknown", errorKind(errors.New("foo")))
+}
@@ -1,54 +1,205 @@
 package mgr
 
 import (
+	"fmt"
 	"runtime"
-	"strconv"
+	"sync"
+	"time"
 )
 
+type memStatsField struct {
+	name  string
+	kind  Kind
+	value func(stats *runtime.MemStats) Value
+}
+
+func memStatsUint64(name string, kind Kind, fn func(stats *runtime.MemStats) uint64) memStatsField {
+	return memStatsField{name, kind, func(stats *runtime.MemStats) Value { return Uint64Value(fn(stats)) }}
+}
+
+// memStatsFields are the fields of runtime.MemStats reported by MemStats, in order.
+var memStatsFields = []memStatsField{
+	memStatsUint64("Alloc", KindGauge, func(s *runtime.MemStats) uint64 { return s.Alloc }),
+	memStatsUint64("TotalAlloc", KindCounter, func(s *runtime.MemStats) uint64 { return s.TotalAlloc }),
+	memStatsUint64("Sys", KindGauge, func(s *runtime.MemStats) uint64 { return s.Sys }),
+	memStatsUint64("Lookups", KindCounter, func(s *runtime.MemStats) uint64 { return s.Lookups }),
+	memStatsUint64("Mallocs", KindCounter, func(s *runtime.MemStats) uint64 { return s.Mallocs }),
+	memStatsUint64("Frees", KindCounter, func(s *runtime.MemStats) uint64 { return s.Frees }),
+	memStatsUint64("HeapAlloc", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapAlloc }),
+	memStatsUint64("HeapSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapSys }),
+	memStatsUint64("HeapIdle", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapIdle }),
+	memStatsUint64("HeapInuse", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapInuse }),
+	memStatsUint64("HeapReleased", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapReleased }),
+	memStatsUint64("HeapObjects", KindGauge, func(s *runtime.MemStats) uint64 { return s.HeapObjects }),
+	memStatsUint64("StackInuse", KindGauge, func(s *runtime.MemStats) uint64 { return s.StackInuse }),
+	memStatsUint64("StackSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.StackSys }),
+	memStatsUint64("MSpanInuse", KindGauge, func(s *runtime.MemStats) uint64 { return s.MSpanInuse }),
+	memStatsUint64("MSpanSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.MSpanSys }),
+	memStatsUint64("MCacheInuse", KindGauge, func(s *runtime.MemStats) uint64 { return s.MCacheInuse }),
+	memStatsUint64("MCacheSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.MCacheSys }),
+	memStatsUint64("BuckHashSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.BuckHashSys }),
+	memStatsUint64("GCSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.GCSys }),
+	memStatsUint64("OtherSys", KindGauge, func(s *runtime.MemStats) uint64 { return s.OtherSys }),
+	memStatsUint64("NextGC", KindGauge, func(s *runtime.MemStats) uint64 { return s.NextGC }),
+	memStatsUint64("LastGC", KindGauge, func(s *runtime.MemStats) uint64 { return s.LastGC }),
+	memStatsUint64("PauseTotalNs", KindCounter, func(s *runtime.MemStats) uint64 { return s.PauseTotalNs }),
+	memStatsUint64("MostRecentPauseNs", KindGauge, func(s *runtime.MemStats) uint64 { return s.PauseNs[(s.NumGC+255)%256] }),
+	memStatsUint64("MostRecentPauseEnd", KindGauge, func(s *runtime.MemStats) uint64 { return s.PauseEnd[(s.NumGC+255)%256] }),
+	memStatsUint64("NumGC", KindCounter, func(s *runtime.MemStats) uint64 { return uint64(s.NumGC) }),
+	{"GCCPUFraction", KindGauge, func(s *runtime.MemStats) Value { return Float64Value(s.GCCPUFraction) }},
+	{"EnableGC", KindGauge, func(s *runtime.MemStats) Value { return BoolValue(s.EnableGC) }},
+	{"DebugGC", KindGauge, func(s *runtime.MemStats) Value { return BoolValue(s.DebugGC) }},
+}
+
 // MemStats is a function that returns data from runtime.MemStats.
 // It is not published by default; you need to publish it yourself.
 // The reason behind this is because it's not a free operation to read runtime memory statistics.
 //
 // Here is how to publish it:
 //
-//     mgr.publish(mgr.Func(MemStats))
+//	mgr.Publish(mgr.Func(mgr.MemStats))
+//
+// See MemStatsCollector to report only some fields and limit how often the statistics are read.
 func MemStats() []KeyValue {
 	stats := new(runtime.MemStats)
 	runtime.ReadMemStats(stats)
 
-	mostRecentPauseNs := stats.PauseNs[(stats.NumGC+255)%256]
-	mostRecentPauseEnd := stats.PauseEnd[(stats.NumGC+255)%256]
-
-	return []KeyValue{
-		{"memstats.Alloc", strconv.FormatUint(stats.Alloc, 10)},
-		{"memstats.TotalAlloc", strconv.FormatUint(stats.TotalAlloc, 10)},
-		{"memstats.Sys", strconv.FormatUint(stats.Sys, 10)},
-		{"memstats.Lookups", strconv.FormatUint(stats.Lookups, 10)},
-		{"memstats.Mallocs", strconv.FormatUint(stats.Mallocs, 10)},
-		{"memstats.Frees", strconv.FormatUint(stats.Frees, 10)},
-		{"memstats.HeapAlloc", strconv.FormatUint(stats.HeapAlloc, 10)},
-		{"memstats.HeapSys", strconv.FormatUint(stats.HeapSys, 10)},
-		{"memstats.HeapIdle", strconv.FormatUint(stats.HeapIdle, 10)},
-		{"memstats.HeapInuse", strconv.FormatUint(stats.HeapInuse, 10)},
-		{"memstats.HeapReleased", strconv.FormatUint(stats.HeapReleased, 10)},
-		{"memstats.HeapObjects", strconv.FormatUint(stats.HeapObjects, 10)},
-		{"memstats.StackInuse", strconv.FormatUint(stats.StackInuse, 10)},
-		{"memstats.StackSys", strconv.FormatUint(stats.StackSys, 10)},
-		{"memstats.MSpanInuse", strconv.FormatUint(stats.MSpanInuse, 10)},
-		{"memstats.MSpanSys", strconv.FormatUint(stats.MSpanSys, 10)},
-		{"memstats.MCacheInuse", strconv.FormatUint(stats.MCacheInuse, 10)},
-		{"memstats.MCacheSys", strconv.FormatUint(stats.MCacheSys, 10)},
-		{"memstats.BuckHashSys", strconv.FormatUint(stats.BuckHashSys, 10)},
-		{"memstats.GCSys", strconv.FormatUint(stats.GCSys, 10)},
-		{"memstats.OtherSys", strconv.FormatUint(stats.OtherSys, 10)},
-		{"memstats.NextGC", strconv.FormatUint(stats.NextGC, 10)},
-		{"memstats.LastGC", strconv.FormatUint(stats.LastGC, 10)},
-		{"memstats.PauseTotalNs", strconv.FormatUint(stats.PauseTotalNs, 10)},
-		{"memstats.MostRecentPauseNs", strconv.FormatUint(mostRecentPauseNs, 10)},
-		{"memstats.MostRecentPauseEnd", strconv.FormatUint(mostRecentPauseEnd, 10)},
-		{"memstats.NumGC", strconv.FormatUint(uint64(stats.NumGC), 10)},
-		{"memstats.GCCPUFraction", strconv.FormatFloat(stats.GCCPUFraction, 'g', -1, 64)},
-		{"memstats.EnableGC", strconv.FormatBool(stats.EnableGC)},
-		{"memstats.DebugGC", strconv.FormatBool(stats.DebugGC)},
+	res := make([]KeyValue, len(memStatsFields))
+	for i, field := range memStatsFields {
+		res[i] = KeyValue{"memstats." + field.name, field.value(stats).String()}
+	}
+
+	return res
+}
+
+// Derived fields reported by MemStatsCollector in addition to the fields of runtime.MemStats.
+const (
+	// MemStatsHeapUtilization is the ratio of HeapAlloc to HeapSys.
+	MemStatsHeapUtilization = "HeapUtilization"
+	// MemStatsAllocRate is the number of bytes allocated per second between the last two reads.
+	MemStatsAllocRate = "AllocRate"
+)
+
+// MemStatsCollector is a variable reporting a selection of the fields of runtime.MemStats that satisfies the Var interface.
+//
+// Reading the memory statistics stops the world, so the statistics read are cached and reused
+// by the exports happening within the maximum age given to NewMemStatsCollector.
+type MemStatsCollector struct {
+	key    string
+	maxAge time.Duration
+
+	mu              sync.Mutex
+	clock           Clock
+	fields          []memStatsField
+	heapUtilization bool
+	allocRate       bool
+
+	stats     runtime.MemStats
+	readAt    time.Time
+	rate      float64
+	haveStats bool
+}
+
+// NewMemStatsCollector creates a MemStatsCollector and publishes it.
+//
+// `fields` are the names of the fields of runtime.MemStats to report, like "HeapAlloc", and the
+// derived MemStatsHeapUtilization and MemStatsAllocRate. If no field is given, every field is reported.
+// The statistics are read at most once every `maxAge`, 0 meaning every export.
+func NewMemStatsCollector(name string, maxAge time.Duration, fields ...string) (*MemStatsCollector, error) {
+	c := &MemStatsCollector{key: name, maxAge: maxAge, clock: SystemClock}
+
+	if len(fields) == 0 {
+		c.fields = memStatsFields
+		c.heapUtilization = true
+		c.allocRate = true
+	}
+
+	for _, name := range fields {
+		switch name {
+		case MemStatsHeapUtilization:
+			c.heapUtilization = true
+			continue
+		case MemStatsAllocRate:
+			c.allocRate = true
+			continue
+		}
+
+		found := false
+		for _, field := range memStatsFields {
+			if field.name == name {
+				c.fields = append(c.fields, field)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown memstats field %q", name)
+		}
 	}
+
+	Publish(c)
+
+	return c, nil
+}
+
+// SetClock sets the clock used to decide whether the cached statistics are recent enough
+// and to compute the allocation rate, SystemClock by default.
+func (c *MemStatsCollector) SetClock(clock Clock) *MemStatsCollector {
+	c.mu.Lock()
+	c.clock = clock
+	c.mu.Unlock()
+
+	return c
+}
+
+// read reads the memory statistics unless the cached ones are recent enough.
+func (c *MemStatsCollector) read() {
+	now := c.clock.Now()
+	if c.haveStats && now.Sub(c.readAt) < c.maxAge {
+		return
+	}
+
+	prevTotalAlloc, prevReadAt := c.stats.TotalAlloc, c.readAt
+	runtime.ReadMemStats(&c.stats)
+
+	if c.haveStats {
+		if elapsed := now.Sub(prevReadAt).Seconds(); elapsed > 0 {
+			c.rate = float64(c.stats.TotalAlloc-prevTotalAlloc) / elapsed
+		}
+	}
+	c.readAt = now
+	c.haveStats = true
 }
+
+func (c *MemStatsCollector) Items() []KeyValue { return formatSamples(c.Samples()) }
+
+// Samples returns the selected fields of the memory statistics.
+func (c *MemStatsCollector) Samples() []Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.read()
+
+	res := make([]Sample, 0, len(c.fields)+2)
+	for _, field := range c.fields {
+		res = append(res, Sample{Key: JoinKey(c.key, field.name), Value: field.value(&c.stats), Kind: field.kind})
+	}
+
+	if c.heapUtilization {
+		var utilization float64
+		if c.stats.HeapSys > 0 {
+			utilization = float64(c.stats.HeapAlloc) / float64(c.stats.HeapSys)
+		}
+		res = append(res, Sample{Key: JoinKey(c.key, MemStatsHeapUtilization), Value: Float64Value(utilization), Kind: KindGauge})
+	}
+	if c.allocRate {
+		res = append(res, Sample{Key: JoinKey(c.key, MemStatsAllocRate), Value: Float64Value(c.rate), Kind: KindGauge})
+	}
+
+	return res
+}
+
+var (
+	_ Var     = (*MemStatsCollector)(nil)
+	_ Sampler = (*MemStatsCollector)(nil)
+)
@@ -0,0 +1,55 @@
+package mgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemStatsKeys(t *testing.T) {
+	items := MemStats()
+
+	require.Len(t, items, 30)
+	require.Equal(t, "memstats.Alloc", items[0].Key)
+	require.Equal(t, "memstats.MostRecentPauseNs", items[24].Key)
+	require.Equal(t, "memstats.DebugGC", items[29].Key)
+	require.Equal(t, "true", items[28].Value)
+}
+
+func TestMemStatsCollector(t *testing.T) {
+	_, fn := reset()
+	defer fn()
+
+	c, err := NewMemStatsCollector("memstats", time.Minute, "HeapAlloc", "NumGC", MemStatsHeapUtilization, MemStatsAllocRate)
+	require.Nil(t, err)
+
+	c.SetClock(fakeClock(1000))
+
+	var keys []string
+	for _, s := range c.Samples() {
+		keys = append(keys, s.Key)
+	}
+	require.Equal(t, []string{"memstats.HeapAlloc", "memstats.NumGC", "memstats.HeapUtilization", "memstats.AllocRate"}, keys)
+
+	// Within the maximum age, the cached statistics are reused.
+	readAt := c.readAt
+	allocs := make([][]byte, 0, 100)
+	for i := 0; i < 100; i++ {
+		allocs = append(allocs, make([]byte, 1024))
+	}
+	c.SetClock(fakeClock(1030))
+	c.Samples()
+	require.Equal(t, readAt, c.readAt)
+
+	c.SetClock(fakeClock(1060))
+	samples := c.Samples()
+	require.Equal(t, time.Unix(1060, 0), c.readAt)
+	require.True(t, samples[3].Value.Float64() > 0)
+	require.Len(t, allocs, 100)
+}
+
+func TestMemStatsCollectorUnknownField(t *testing.T) {
+	_, err := NewMemStatsCollector("memstats", 0, "HeapAllocs")
+	require.Equal(t, `unknown memstats field "HeapAllocs"`, err.Error())
+}
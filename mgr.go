@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"math"
+	"math/rand"
 	"net"
 	"sort"
-	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,13 +21,63 @@ type Config struct {
 	// Addr address of the Graphite server (with the port).
 	Addr string
 	// Prefix is used to prefix every metrics reported to Graphite.
+	// It can contain placeholders, see ExpandPrefix.
 	Prefix string
 	// Logger allows you to override the logger used to report errors.
 	Logger func(format string, args ...interface{})
+	// Slog, if set, is used instead of Logger to log structured records.
+	// Failed exports are logged at the warning level until they persist, at which point they are logged as errors.
+	Slog *slog.Logger
+	// Align aligns the exports to wall clock multiples of Interval, for example at :00, :10, :20 with a 10s interval,
+	// and stamps the data with the aligned time, so that every process of a fleet lands in the same Graphite bucket.
+	Align bool
+	// Jitter delays each export by a random duration up to Jitter, to avoid every process sending at the same time.
+	// When Align is set the data is still stamped with the aligned time.
+	Jitter time.Duration
+	// Format is the wire format of the exported data, which also defines the precision of the timestamps.
+	// Defaults to the Graphite plaintext protocol with a precision of one second.
+	Format Format
+	// Clock allows you to override the clock used to timestamp the data, mainly for tests.
+	Clock Clock
+	// MaxBatchLines is the maximum number of lines written at once, 0 means unlimited.
+	MaxBatchLines int
+	// MaxBatchBytes is the maximum number of bytes written at once, 0 means unlimited.
+	// A single line longer than MaxBatchBytes is still written on its own.
+	MaxBatchBytes int
+	// StatsPrefix, if set, is the key under which the exporter reports its own statistics (see Stats).
+	// The statistics reported by an export are the ones as of the end of the previous export.
+	StatsPrefix string
+}
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the Clock used unless configured otherwise.
+var SystemClock Clock = systemClock{}
+
+func (c *Config) clock() Clock {
+	if c == nil || c.Clock == nil {
+		return SystemClock
+	}
+	return c.Clock
+}
+
+func (c *Config) format() Format {
+	if c == nil || c.Format == nil {
+		return Graphite
+	}
+	return c.Format
 }
 
 var (
 	// ErrInvalidConfig is returned when the configuration is invalid (missing Graphite address mainly).
+	// The errors returned by Config.Validate wrap it with the details.
 	ErrInvalidConfig = errors.New("invalid config")
 
 	// DiscardLogger can be used as a Logger if you want to silence the errors.
@@ -48,6 +98,14 @@ type Func func() []KeyValue
 
 func (f Func) Items() []KeyValue { return f() }
 
+// SampleFunc implements Sampler by calling the function.
+// Unlike Func, the function can report typed values and timestamps.
+type SampleFunc func() []Sample
+
+func (f SampleFunc) Items() []KeyValue { return formatSamples(f()) }
+
+func (f SampleFunc) Samples() []Sample { return f() }
+
 // KeyValue represents a single Graphite metric.
 type KeyValue struct {
 	Key   string
@@ -55,16 +113,23 @@ type KeyValue struct {
 }
 
 // Int is a 64-bit integer variable that satisfies the Var interface.
+//
+// Since it can be set and decreased, it is used both as a counter and as a gauge:
+// its value is reported as untyped. Use IntFunc to report a gauge.
 type Int struct {
 	key string
 	i   int64
 }
 
 // Items returns the value in a 1-size KeyValue slice.
-func (i *Int) Items() []KeyValue {
-	return []KeyValue{{
+func (i *Int) Items() []KeyValue { return formatSamples(i.Samples()) }
+
+// Samples returns the value in a 1-size Sample slice.
+func (i *Int) Samples() []Sample {
+	return []Sample{{
 		Key:   i.key,
-		Value: strconv.FormatInt(atomic.LoadInt64(&i.i), 10),
+		Value: Int64Value(atomic.LoadInt64(&i.i)),
+		Kind:  KindUntyped,
 	}}
 }
 
@@ -89,10 +154,14 @@ type Float struct {
 }
 
 // Items returns the value in a 1-size KeyValue slice.
-func (f *Float) Items() []KeyValue {
-	return []KeyValue{{
+func (f *Float) Items() []KeyValue { return formatSamples(f.Samples()) }
+
+// Samples returns the value in a 1-size Sample slice.
+func (f *Float) Samples() []Sample {
+	return []Sample{{
 		Key:   f.key,
-		Value: strconv.FormatFloat(math.Float64frombits(atomic.LoadUint64(&f.f)), 'g', -1, 64),
+		Value: Float64Value(math.Float64frombits(atomic.LoadUint64(&f.f))),
+		Kind:  KindGauge,
 	}}
 }
 
@@ -144,33 +213,89 @@ func (m *Map) Init() *Map {
 	return m
 }
 
-func flattenMap(prefix string, m map[string]Var, keys []string) (res []KeyValue) {
+// flattenMap returns the samples of every entry of the map.
+// If `onPanic` is not nil, a panic of an entry is recovered and passed to it, and the entry is skipped.
+func flattenMap(prefix string, m map[string]Var, keys []string, onPanic func(r interface{})) (res []Sample) {
 	for _, k := range keys {
 		val := m[k]
-		key := prefix + "." + k
+		key := JoinKey(prefix, k)
 
 		switch v := val.(type) {
 		case *Map:
-			res = append(res, flattenMap(key, v.m, v.keys)...)
+			res = append(res, flattenMap(key, v.m, v.keys, onPanic)...)
 		default:
-			for _, item := range v.Items() {
-				res = append(res, KeyValue{
-					Key:   key,
-					Value: item.Value,
-				})
+			l := entrySamples(v, onPanic)
+			if len(l) == 0 {
+				continue
+			}
+			if singleValue(v, len(l)) {
+				// A single value is reported under the entry key, whatever the key of the variable.
+				l[0].Key = key
+				res = append(res, l[0])
+				continue
+			}
+			for _, s := range l {
+				s.Key = JoinKey(key, s.Key)
+				res = append(res, s)
 			}
 		}
 	}
 	return
 }
 
-func (m *Map) Items() []KeyValue {
+// entrySamples returns the samples of the map entry `v`, recovering from a panic if `onPanic` is not nil.
+func entrySamples(v Var, onPanic func(r interface{})) (res []Sample) {
+	if onPanic != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				onPanic(r)
+				res = nil
+			}
+		}()
+	}
+
+	return samples(v)
+}
+
+// singleValue returns true if the variable `v`, which reported `n` samples, holds a single value.
+// Variables which can report several samples, like Histogram, are never considered single values
+// so that their series names don't depend on their configuration.
+func singleValue(v Var, n int) bool {
+	switch v.(type) {
+	case *Int, *Float, *ShardedInt, *ShardedFloat, *GaugeFunc, *IntFunc:
+		return true
+	case Func, SampleFunc:
+		return n == 1
+	case Sampler:
+		return false
+	default:
+		return n == 1
+	}
+}
+
+func (m *Map) Items() []KeyValue { return formatSamples(m.Samples()) }
+
+// Samples returns the samples of every entry of the map, with their kind preserved.
+func (m *Map) Samples() []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return flattenMap(m.key, m.m, m.keys, nil)
+}
+
+// safeSamples returns the same samples as Samples, except that the entries which panic are skipped
+// after passing the panic to `onPanic`.
+func (m *Map) safeSamples(onPanic func(r interface{})) []Sample {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return flattenMap(m.key, m.m, m.keys)
+	return flattenMap(m.key, m.m, m.keys, onPanic)
 }
 
+// Set sets the entry `key` of the map to `val`.
+// If `val` holds a single value, like an Int, it is reported under the map key followed by `key`.
+// Otherwise its samples are reported under the map key followed by `key` and the key of each sample,
+// for example "<map>.<key>.<histogram key>.mean" for a Histogram.
 func (m *Map) Set(key string, val Var) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -217,34 +342,56 @@ func Do(fn func(v Var)) {
 	vars.Unlock()
 }
 
+// Export exports the published variables to Graphite every config.Interval.
+// It validates the configuration first and returns immediately if it is invalid, otherwise it never returns.
+// The configuration is not modified.
 func Export(config *Config) error {
-	if config == nil {
-		return ErrInvalidConfig
+	if err := config.Validate(); err != nil {
+		return err
 	}
 
-	if config.Interval == 0 {
-		config.Interval = 1 * time.Minute
-	}
-	if config.Logger == nil {
-		config.Logger = log.Printf
+	config = config.withDefaults()
+
+	if config.Align {
+		for {
+			now := config.clock().Now()
+			tick := nextTick(now, config.Interval)
+			time.Sleep(tick.Sub(now) + jitter(config.Jitter))
+
+			// Errors are logged by reportAt.
+			_ = reportAt(config, tick)
+		}
 	}
 
 	ticker := time.NewTicker(config.Interval)
 	for range ticker.C {
-		if err := report(config); err != nil {
-			config.Logger("unable to report data. err=%v", err)
-		}
+		time.Sleep(jitter(config.Jitter))
+
+		// Errors are logged by report.
+		_ = report(config)
 	}
 
 	return nil
 }
 
+// nextTick returns the first multiple of `interval` since the Unix epoch after `now`,
+// which is the start of the next Graphite bucket of `interval` seconds.
+func nextTick(now time.Time, interval time.Duration) time.Time {
+	tick := (now.UnixNano()/int64(interval) + 1) * int64(interval)
+	return time.Unix(0, tick).In(now.Location())
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
 type dialFunc func(config *Config) (io.Writer, error)
-type timeFunc func() int64
 
 var (
 	dialFn dialFunc = defaultDial
-	timeFn timeFunc = defaulTimeNow
 	conn   io.Writer
 
 	bufPool = sync.Pool{
@@ -252,45 +399,99 @@ var (
 	}
 )
 
+// maxPooledBufferSize is the capacity above which a buffer is not put back in bufPool,
+// so that a single large export doesn't retain a huge buffer forever.
+const maxPooledBufferSize = 1 << 20
+
 func defaultDial(config *Config) (io.Writer, error) {
 	return net.Dial("tcp", config.Addr)
 }
 
-func defaulTimeNow() int64 {
-	return time.Now().UnixNano() / int64(time.Second)
+// safeSamples returns the samples of `v`, recovering from a panic so that a single
+// faulty variable can't kill the exporter goroutine.
+func safeSamples(config *Config, v Var) (res []Sample) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(config, r)
+			res = nil
+		}
+	}()
+
+	// Recover from the panics of the entries of a map separately, to still report the other entries.
+	if m, ok := v.(*Map); ok {
+		return m.safeSamples(func(r interface{}) { logPanic(config, r) })
+	}
+
+	return samples(v)
 }
 
-func appendMetric(config *Config, buf *bytes.Buffer, v Var) {
+// appendMetric appends the samples of `v` to the batch.
+// Samples without a timestamp are stamped with `now`, which is shared by every variable of an export.
+func appendMetric(config *Config, b *batch, v Var, now time.Time) {
 	var prefix string
 	if config != nil && config.Prefix != "" {
 		prefix = config.Prefix + "."
 	}
 
-	for _, kv := range v.Items() {
-		buf.WriteString(prefix + kv.Key + " " + kv.Value + " ")
-		buf.WriteString(strconv.FormatInt(timeFn(), 10))
-		buf.WriteRune('\n')
+	format := config.format()
+
+	var line []byte
+	for _, s := range safeSamples(config, v) {
+		ts := now
+		if !s.Timestamp.IsZero() {
+			ts = s.Timestamp
+		}
+
+		line = format.Append(line[:0], prefix+s.Key, s.Value, ts)
+		b.add(line)
 	}
 }
 
 func report(config *Config) error {
+	return reportAt(config, config.clock().Now())
+}
+
+// reportAt reports every variable, stamping the samples without a timestamp with `now`.
+func reportAt(config *Config, now time.Time) (err error) {
+	start := time.Now()
+
+	// Copy the variables so that the global list isn't locked while writing to the network.
+	var l []Var
+	Do(func(v Var) { l = append(l, v) })
+
+	b := newBatch(config)
+	defer func() {
+		attempt := exporterStats.record(b, len(l), now, time.Since(start), err)
+		logExport(config, b, attempt, err)
+		b.release()
+	}()
+
 	if conn == nil {
-		var err error
-		conn, err = dialFn(config)
-		if err != nil {
-			return err
+		if err := dial(config); err != nil {
+			// The lines are still produced to be counted as dropped.
+			b.drop(err)
 		}
 	}
 
-	buf := bufPool.Get().(*bytes.Buffer)
-	defer bufPool.Put(buf)
+	for _, v := range l {
+		appendMetric(config, b, v, now)
+	}
+	if config != nil && config.StatsPrefix != "" {
+		appendMetric(config, b, SampleFunc(func() []Sample { return exporterStats.samples(config.StatsPrefix) }), now)
+	}
+	b.flush()
 
-	Do(func(v Var) { appendMetric(config, buf, v) })
+	return b.err()
+}
 
-	_, err := io.Copy(conn, buf)
+// dial connects to the Graphite server.
+func dial(config *Config) (err error) {
+	conn, err = dialFn(config)
 	if err != nil {
-		conn = nil
-		return err
+		return &exportError{kind: "dial", err: err}
+	}
+	if exporterStats.recordConnection() {
+		logReconnect(config)
 	}
 
 	return nil
@@ -298,7 +499,13 @@ func report(config *Config) error {
 
 var (
 	_ Var = (Func)(nil)
+	_ Var = (SampleFunc)(nil)
 	_ Var = (*Int)(nil)
 	_ Var = (*Float)(nil)
 	_ Var = (*Map)(nil)
+
+	_ Sampler = (SampleFunc)(nil)
+	_ Sampler = (*Int)(nil)
+	_ Sampler = (*Float)(nil)
+	_ Sampler = (*Map)(nil)
 )
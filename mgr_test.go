@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -16,6 +17,7 @@ import (
 func reset() (*bytes.Buffer, func()) {
 	conn = nil
 	vars.l = nil
+	exporterStats.reset()
 
 	buf := new(bytes.Buffer)
 	dialFn = func(_ *Config) (io.Writer, error) {
@@ -26,6 +28,21 @@ func reset() (*bytes.Buffer, func()) {
 	return buf, resetDialFn
 }
 
+// fakeClock is a Clock always returning the same unix time.
+type fakeClock int64
+
+func (c fakeClock) Now() time.Time { return time.Unix(int64(c), 0) }
+
+// tickingClock is a Clock advancing by one second each time it's read.
+type tickingClock struct {
+	now int64
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.now++
+	return time.Unix(c.now, 0)
+}
+
 func TestEmpty(t *testing.T) {
 	buf, fn := reset()
 	defer fn()
@@ -42,9 +59,9 @@ func TestInt(t *testing.T) {
 	i := NewInt("foobar")
 	i.Set(50)
 
-	timeFn = func() int64 { return 100 }
+	config := &Config{Clock: fakeClock(100)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar 50 100\n", buf.String())
 
@@ -52,7 +69,7 @@ func TestInt(t *testing.T) {
 
 	buf.Reset()
 
-	err = report(nil)
+	err = report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar 170 100\n", buf.String())
 }
@@ -84,9 +101,9 @@ func TestFloat(t *testing.T) {
 	f := NewFloat("foobar")
 	f.Set(50.1)
 
-	timeFn = func() int64 { return 100 }
+	config := &Config{Clock: fakeClock(100)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar 50.1 100\n", buf.String())
 }
@@ -126,9 +143,9 @@ func TestConcurrentInt(t *testing.T) {
 	}
 	wg.Wait()
 
-	timeFn = func() int64 { return 100 }
+	config := &Config{Clock: fakeClock(100)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar 4000 100\n", buf.String())
 }
@@ -151,9 +168,9 @@ func TestMultipleConcurrent(t *testing.T) {
 	}
 	wg.Wait()
 
-	timeFn = func() int64 { return 100 }
+	config := &Config{Clock: fakeClock(100)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar.int 4000 100\nfoobar.float 4000 100\n", buf.String())
 }
@@ -174,13 +191,35 @@ func TestMap(t *testing.T) {
 	m.Set("i", &i)
 	m.Set("f", &f)
 
-	timeFn = func() int64 { return 540 }
+	config := &Config{Clock: fakeClock(540)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foobar.f 20.3 540\nfoobar.i 100 540\n", buf.String())
 }
 
+func TestMapKeyedChild(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	i := &Int{key: "hits"}
+	i.Set(3)
+
+	var h Histogram
+	h.key = "latency"
+	h.Init(2).Configure(StatMean | StatMax)
+	h.Record(1)
+	h.Record(2)
+
+	m := NewMap("http")
+	m.Set("api", i)
+	m.Set("db", &h)
+
+	err := report(&Config{Clock: fakeClock(600)})
+	require.Nil(t, err)
+	require.Equal(t, "http.api 3 600\nhttp.db.latency.mean 1.5 600\nhttp.db.latency.max 2 600\n", buf.String())
+}
+
 func ExampleMap() {
 	httpStats := NewMap("mymap")
 	var (
@@ -286,9 +325,9 @@ func TestMapInMap(t *testing.T) {
 	m.Set("bar", &m1)
 	m.Set("baz", &m2)
 
-	timeFn = func() int64 { return 600 }
+	config := &Config{Clock: fakeClock(600)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 	require.Equal(t, "foo.bar.i 10 600\nfoo.baz.i 500 600\nfoo.baz.m.d 209 600\n", buf.String())
 }
@@ -302,9 +341,9 @@ func TestMemstats(t *testing.T) {
 	i := NewInt("foobar.i")
 	i.Set(3050)
 
-	timeFn = func() int64 { return 606 }
+	config := &Config{Clock: fakeClock(606)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 
 	scanner := bufio.NewScanner(buf)
@@ -366,9 +405,9 @@ func TestCustomVar(t *testing.T) {
 
 	Publish(&cv)
 
-	timeFn = func() int64 { return 606 }
+	config := &Config{Clock: fakeClock(606)}
 
-	err := report(nil)
+	err := report(config)
 	require.Nil(t, err)
 
 	scanner := bufio.NewScanner(buf)
@@ -395,9 +434,7 @@ func TestPrefix(t *testing.T) {
 	f := NewFloat("f")
 	f.Set(404.32)
 
-	timeFn = func() int64 { return 606 }
-
-	err := report(&Config{Prefix: "foobar"})
+	err := report(&Config{Prefix: "foobar", Clock: fakeClock(606)})
 	require.Nil(t, err)
 
 	scanner := bufio.NewScanner(buf)
@@ -410,3 +447,71 @@ func TestPrefix(t *testing.T) {
 	require.Equal(t, "foobar.i 303 606", lines[0])
 	require.Equal(t, "foobar.f 404.32 606", lines[1])
 }
+
+func TestSampleTimestamp(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	Publish(SampleFunc(func() []Sample {
+		return []Sample{
+			{Key: "sidecar.a", Value: Int64Value(1), Timestamp: time.Unix(500, 0)},
+			{Key: "sidecar.b", Value: Int64Value(2)},
+		}
+	}))
+
+	config := &Config{Clock: fakeClock(606)}
+
+	err := report(config)
+	require.Nil(t, err)
+	require.Equal(t, "sidecar.a 1 500\nsidecar.b 2 606\n", buf.String())
+}
+
+func TestSingleTimestampPerExport(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+	NewFloat("c")
+
+	clock := &tickingClock{now: 100}
+
+	err := report(&Config{Clock: clock})
+	require.Nil(t, err)
+	require.Equal(t, "a 0 101\nb 0 101\nc 0 101\n", buf.String())
+}
+
+func TestNextTick(t *testing.T) {
+	now := time.Date(2016, 1, 2, 10, 20, 34, 500, time.UTC)
+
+	require.Equal(t, time.Date(2016, 1, 2, 10, 20, 40, 0, time.UTC), nextTick(now, 10*time.Second))
+	require.Equal(t, time.Date(2016, 1, 2, 10, 21, 0, 0, time.UTC), nextTick(now, time.Minute))
+	require.Equal(t, time.Date(2016, 1, 2, 10, 25, 0, 0, time.UTC), nextTick(now, 5*time.Minute))
+
+	aligned := time.Date(2016, 1, 2, 10, 20, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2016, 1, 2, 10, 21, 0, 0, time.UTC), nextTick(aligned, time.Minute))
+
+	// Intervals which don't divide a day are aligned on the Unix epoch like the Graphite buckets.
+	tick := nextTick(time.Unix(1700000003, 0), 7*time.Second)
+	require.Equal(t, int64(1700000008), tick.Unix())
+	require.Equal(t, int64(0), tick.Unix()%7)
+}
+
+func TestJitter(t *testing.T) {
+	require.Equal(t, time.Duration(0), jitter(0))
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		require.True(t, d >= 0 && d < time.Second)
+	}
+}
+
+func TestReportAt(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	NewInt("foobar").Set(10)
+
+	err := reportAt(nil, time.Unix(1200, 0))
+	require.Nil(t, err)
+	require.Equal(t, "foobar 10 1200\n", buf.String())
+}
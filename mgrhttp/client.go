@@ -0,0 +1,266 @@
+package mgrhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	mgr "github.com/vrischmann/mgraphite"
+)
+
+// errorKinds are the kinds of errors counted for each label.
+var errorKinds = [...]string{"dns", "connect", "tls", "timeout", "other"}
+
+const (
+	errorDNS = iota
+	errorConnect
+	errorTLS
+	errorTimeout
+	errorOther
+)
+
+// Transport is a http.RoundTripper recording metrics about the requests made through it.
+//
+// The metrics are reported under the key of the transport followed by a label, by default the host of the request
+// with its dots and colons replaced by underscores:
+//
+//	<name>.<label>.requests
+//	<name>.<label>.errors.<kind>
+//	<name>.<label>.status.2xx
+//	<name>.<label>.latency.<stat>
+//
+// The error kinds are dns, connect, tls, timeout and other. The latency, in nanoseconds, is the time until
+// the response headers are received. If tracing is enabled, the durations of the phases of the requests are
+// also reported as histograms named dns, connect, tls_handshake and ttfb (time to first byte).
+type Transport struct {
+	base       http.RoundTripper
+	m          *mgr.Map
+	maxLabels  int
+	bufferSize int
+
+	label func(r *http.Request) string
+	trace bool
+
+	// mu protects labels, the existing labels are looked up with a read lock.
+	mu     sync.RWMutex
+	labels map[string]*clientMetrics
+}
+
+type clientMetrics struct {
+	requests mgr.Int
+	errors   [len(errorKinds)]mgr.Int
+	status   [len(statusClasses)]mgr.Int
+	latency  *mgr.Histogram
+
+	dns          *mgr.Histogram
+	connect      *mgr.Histogram
+	tlsHandshake *mgr.Histogram
+	ttfb         *mgr.Histogram
+}
+
+// NewTransport creates a Transport wrapping `base` and publishes its metrics under `name`.
+// If `base` is nil, http.DefaultTransport is used.
+//
+// At most `maxLabels` labels are recorded, the requests with the labels seen afterwards are recorded under OtherRoute.
+// `bufferSize` is the buffer size of the histograms of each label.
+func NewTransport(name string, base http.RoundTripper, maxLabels, bufferSize int) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		base:       base,
+		m:          mgr.NewMap(name),
+		maxLabels:  maxLabels,
+		bufferSize: bufferSize,
+		label:      hostLabel,
+		labels:     make(map[string]*clientMetrics),
+	}
+}
+
+// hostLabel returns the host of the request.
+func hostLabel(r *http.Request) string {
+	return r.URL.Host
+}
+
+// Label sets the function returning the label under which a request is recorded.
+// Like the host, the label is made a single Graphite node with mgr.SanitizeNode.
+// Must be called before making requests, it is not safe for concurrent use with RoundTrip.
+func (t *Transport) Label(fn func(r *http.Request) string) *Transport {
+	t.label = fn
+	return t
+}
+
+// Trace enables or disables the recording of the phases of the requests with net/http/httptrace.
+// Must be called before making requests, it is not safe for concurrent use with RoundTrip.
+func (t *Transport) Trace(enabled bool) *Transport {
+	t.trace = enabled
+	return t
+}
+
+// metrics returns the metrics of the label `name`, creating them if needed.
+func (t *Transport) metrics(name string) *clientMetrics {
+	name = mgr.SanitizeNode(name)
+	if name == "" {
+		name = OtherRoute
+	}
+
+	t.mu.RLock()
+	cm, ok := t.labels[name]
+	if !ok && len(t.labels) >= t.maxLabels {
+		cm, ok = t.labels[OtherRoute]
+	}
+	t.mu.RUnlock()
+	if ok {
+		return cm
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cm, ok := t.labels[name]; ok {
+		return cm
+	}
+	if len(t.labels) >= t.maxLabels && name != OtherRoute {
+		if cm, ok := t.labels[OtherRoute]; ok {
+			return cm
+		}
+		name = OtherRoute
+	}
+
+	cm = &clientMetrics{latency: new(mgr.Histogram).Init(t.bufferSize)}
+
+	errs := new(mgr.Map).Init()
+	for i, kind := range errorKinds {
+		errs.Set(kind, &cm.errors[i])
+	}
+	status := new(mgr.Map).Init()
+	for i, class := range statusClasses {
+		status.Set(class, &cm.status[i])
+	}
+
+	m := new(mgr.Map).Init()
+	m.Set("requests", &cm.requests)
+	m.Set("errors", errs)
+	m.Set("status", status)
+	m.Set("latency", cm.latency)
+
+	if t.trace {
+		cm.dns = new(mgr.Histogram).Init(t.bufferSize)
+		cm.connect = new(mgr.Histogram).Init(t.bufferSize)
+		cm.tlsHandshake = new(mgr.Histogram).Init(t.bufferSize)
+		cm.ttfb = new(mgr.Histogram).Init(t.bufferSize)
+
+		m.Set("dns", cm.dns)
+		m.Set("connect", cm.connect)
+		m.Set("tls_handshake", cm.tlsHandshake)
+		m.Set("ttfb", cm.ttfb)
+	}
+
+	t.labels[name] = cm
+	t.m.Set(name, m)
+
+	return cm
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	cm := t.metrics(t.label(r))
+
+	start := time.Now()
+	if cm.dns != nil {
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), cm.clientTrace(start)))
+	}
+
+	cm.requests.Add(1)
+	resp, err := t.base.RoundTrip(r)
+	cm.latency.RecordSince(start)
+
+	if err != nil {
+		cm.errors[errorKind(err)].Add(1)
+		return nil, err
+	}
+	if i := resp.StatusCode/100 - 1; i >= 0 && i < len(cm.status) {
+		cm.status[i].Add(1)
+	}
+
+	return resp, nil
+}
+
+// clientTrace returns a trace recording the phases of a request started at `start`.
+func (cm *clientMetrics) clientTrace(start time.Time) *httptrace.ClientTrace {
+	// The hooks can be called concurrently, for example when dialing several addresses.
+	var (
+		mu                               sync.Mutex
+		dnsStart, connectStart, tlsStart time.Time
+	)
+	since := func(t *time.Time) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Since(*t)
+	}
+	set := func(t *time.Time) {
+		mu.Lock()
+		*t = time.Now()
+		mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { set(&dnsStart) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				cm.dns.Record(int64(since(&dnsStart)))
+			}
+		},
+		ConnectStart: func(network, addr string) { set(&connectStart) },
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				cm.connect.Record(int64(since(&connectStart)))
+			}
+		},
+		TLSHandshakeStart: func() { set(&tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				cm.tlsHandshake.Record(int64(since(&tlsStart)))
+			}
+		},
+		GotFirstResponseByte: func() { cm.ttfb.RecordSince(start) },
+	}
+}
+
+// errorKind classifies an error returned by a http.RoundTripper.
+func errorKind(err error) int {
+	var (
+		dnsErr       *net.DNSError
+		certErr      *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		opErr        *net.OpError
+		timeoutErr   interface{ Timeout() bool }
+	)
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return errorDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return errorTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &timeoutErr) && timeoutErr.Timeout():
+		return errorTimeout
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return errorConnect
+	default:
+		return errorOther
+	}
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
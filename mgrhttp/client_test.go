@@ -0,0 +1,105 @@
+package mgrhttp
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tr := NewTransport("client", nil, 10, 16).
+		Label(func(r *http.Request) string { return "api" }).
+		Trace(true)
+	client := &http.Client{Transport: tr}
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := client.Get(ts.URL + path)
+		require.Nil(t, err)
+		resp.Body.Close()
+	}
+
+	// Nothing listens on the address of a closed server.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err := client.Get(closed.URL)
+	require.NotNil(t, err)
+
+	items := itemsMap(tr.m)
+
+	require.Equal(t, "4", items["client.api.requests"])
+	require.Equal(t, "2", items["client.api.status.2xx"])
+	require.Equal(t, "1", items["client.api.status.4xx"])
+	require.Equal(t, "1", items["client.api.errors.connect"])
+	require.Equal(t, "0", items["client.api.errors.dns"])
+	require.Contains(t, items, "client.api.latency.p99")
+	require.Contains(t, items, "client.api.connect.max")
+	require.NotEqual(t, "0", items["client.api.ttfb.max"])
+}
+
+func TestTransportLabelSanitized(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	tr := NewTransport("client_label", nil, 10, 16).Label(func(r *http.Request) string {
+		return "payments api/v1.2"
+	})
+
+	resp, err := (&http.Client{Transport: tr}).Get(ts.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, "1", itemsMap(tr.m)["client_label.payments_api_v1_2.status.4xx"])
+}
+
+func TestTransportHostLabel(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	tr := NewTransport("client_host", nil, 10, 16)
+
+	resp, err := (&http.Client{Transport: tr}).Get(ts.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	label := strings.NewReplacer(".", "_", ":", "_").Replace(ts.Listener.Addr().String())
+	require.Equal(t, "1", itemsMap(tr.m)["client_host."+label+".status.4xx"])
+	require.NotContains(t, itemsMap(tr.m), "client_host."+label+".dns.max")
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestErrorKind(t *testing.T) {
+	testCases := []struct {
+		err  error
+		kind string
+	}{
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, "dns"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "connect"},
+		{fmt.Errorf("tls: %w", x509.UnknownAuthorityError{}), "tls"},
+		{context.DeadlineExceeded, "timeout"},
+		{&net.OpError{Op: "dial", Err: timeoutError{}}, "timeout"},
+		{errors.New("EOF"), "other"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.kind, errorKinds[errorKind(tc.err)], tc.err.Error())
+	}
+}
@@ -0,0 +1,242 @@
+// Package mgrhttp records metrics about net/http servers and clients into mgr variables.
+package mgrhttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mgr "github.com/vrischmann/mgraphite"
+)
+
+// OtherRoute is the route under which requests are recorded once the maximum number of routes is reached.
+const OtherRoute = "other"
+
+// statusClasses are the names of the status classes counted for each route.
+var statusClasses = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// Server records metrics about the requests handled by the wrapped handlers.
+//
+// The metrics of each route are reported under the key of the server followed by the route name:
+//
+//	<name>.<route>.requests
+//	<name>.<route>.in_flight
+//	<name>.<route>.status.2xx
+//	<name>.<route>.latency.<stat>
+//	<name>.<route>.response_size.<stat>
+//
+// The latency is in nanoseconds and the response size in bytes, both are Histograms.
+// Each route name is made a single Graphite node with mgr.SanitizeNode, for example
+// the pattern "GET /items/{id}" is reported as "GET__items__id_".
+type Server struct {
+	m          *mgr.Map
+	maxRoutes  int
+	bufferSize int
+
+	// mu protects routes, the existing routes are looked up with a read lock.
+	mu     sync.RWMutex
+	routes map[string]*routeMetrics
+}
+
+type routeMetrics struct {
+	requests     mgr.Int
+	inFlight     int64
+	status       [len(statusClasses)]mgr.Int
+	latency      *mgr.Histogram
+	responseSize *mgr.Histogram
+}
+
+// NewServer creates a Server and publishes its metrics under `name`.
+//
+// At most `maxRoutes` routes are recorded, the requests of the routes seen afterwards are recorded under OtherRoute.
+// `bufferSize` is the buffer size of the histograms of each route.
+func NewServer(name string, maxRoutes, bufferSize int) *Server {
+	return &Server{
+		m:          mgr.NewMap(name),
+		maxRoutes:  maxRoutes,
+		bufferSize: bufferSize,
+		routes:     make(map[string]*routeMetrics),
+	}
+}
+
+// route returns the metrics of the route `name`, creating them if needed.
+func (s *Server) route(name string) *routeMetrics {
+	name = mgr.SanitizeNode(name)
+	if name == "" {
+		name = OtherRoute
+	}
+
+	s.mu.RLock()
+	rm, ok := s.routes[name]
+	if !ok && len(s.routes) >= s.maxRoutes {
+		rm, ok = s.routes[OtherRoute]
+	}
+	s.mu.RUnlock()
+	if ok {
+		return rm
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rm, ok := s.routes[name]; ok {
+		return rm
+	}
+	if len(s.routes) >= s.maxRoutes && name != OtherRoute {
+		if rm, ok := s.routes[OtherRoute]; ok {
+			return rm
+		}
+		name = OtherRoute
+	}
+
+	rm = &routeMetrics{
+		latency:      new(mgr.Histogram).Init(s.bufferSize),
+		responseSize: new(mgr.Histogram).Init(s.bufferSize),
+	}
+
+	status := new(mgr.Map).Init()
+	for i, class := range statusClasses {
+		status.Set(class, &rm.status[i])
+	}
+
+	m := new(mgr.Map).Init()
+	m.Set("requests", &rm.requests)
+	m.Set("in_flight", mgr.SampleFunc(func() []mgr.Sample {
+		return []mgr.Sample{{Value: mgr.Int64Value(atomic.LoadInt64(&rm.inFlight)), Kind: mgr.KindGauge}}
+	}))
+	m.Set("status", status)
+	m.Set("latency", rm.latency)
+	m.Set("response_size", rm.responseSize)
+
+	s.routes[name] = rm
+	s.m.Set(name, m)
+
+	return rm
+}
+
+// Wrap returns a handler calling `h` and recording its requests under `route`.
+func (s *Server) Wrap(route string, h http.Handler) http.Handler {
+	rm := s.route(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rm.serve(h, w, r)
+	})
+}
+
+// Handler returns a handler calling `h` and recording each request under the route returned by `route`,
+// for example the pattern matched by a router.
+func (s *Server) Handler(h http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.route(route(r)).serve(h, w, r)
+	})
+}
+
+func (rm *routeMetrics) serve(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &responseWriter{ResponseWriter: w}
+
+	rm.requests.Add(1)
+	atomic.AddInt64(&rm.inFlight, 1)
+
+	completed := false
+	defer func() {
+		atomic.AddInt64(&rm.inFlight, -1)
+
+		status := rw.status
+		switch {
+		case !completed:
+			// The handler panicked, net/http aborts the response.
+			status = http.StatusInternalServerError
+		case status == 0:
+			status = http.StatusOK
+		}
+		if i := status/100 - 1; i >= 0 && i < len(rm.status) {
+			rm.status[i].Add(1)
+		}
+
+		rm.latency.RecordSince(start)
+		rm.responseSize.Record(rw.size)
+	}()
+
+	h.ServeHTTP(rw.wrap(), r)
+	completed = true
+}
+
+// responseWriter records the status code and the size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses can precede the final one.
+	if w.status == 0 || w.status < http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Unwrap returns the original ResponseWriter, allowing http.ResponseController to reach it.
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *responseWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && w.status == 0 {
+		// The handler takes over the connection, most likely to switch protocols.
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+type flushWriter struct{ *responseWriter }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *responseWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *responseWriter }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+// wrap returns the writer passed to the handler, which implements http.Flusher
+// and http.Hijacker only if the original ResponseWriter does.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{w}
+	case flusher:
+		return flushWriter{w}
+	case hijacker:
+		return hijackWriter{w}
+	default:
+		return w
+	}
+}
@@ -0,0 +1,143 @@
+package mgrhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	mgr "github.com/vrischmann/mgraphite"
+)
+
+func itemsMap(v mgr.Var) map[string]string {
+	res := make(map[string]string)
+	for _, item := range v.Items() {
+		res[item.Key] = item.Value
+	}
+	return res
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer("http", 2, 16)
+
+	hello := s.Wrap("hello", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	missing := s.Wrap("missing", http.NotFoundHandler())
+	extra := s.Wrap("extra", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	for i := 0; i < 3; i++ {
+		hello.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	missing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	extra.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	items := itemsMap(s.m)
+
+	require.Equal(t, "3", items["http.hello.requests"])
+	require.Equal(t, "3", items["http.hello.status.2xx"])
+	require.Equal(t, "0", items["http.hello.in_flight"])
+	require.Equal(t, "5", items["http.hello.response_size.max"])
+	require.Contains(t, items, "http.hello.latency.p99")
+
+	require.Equal(t, "1", items["http.missing.status.4xx"])
+
+	// The third route is over the limit.
+	require.Equal(t, "1", items["http.other.requests"])
+	require.Equal(t, "1", items["http.other.status.5xx"])
+	for key := range items {
+		require.False(t, strings.HasPrefix(key, "http.extra."), key)
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	s := NewServer("http_handler", 10, 16)
+
+	var inFlight string
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = itemsMap(s.m)["http_handler.users.in_flight"]
+	}), func(r *http.Request) string {
+		return strings.Trim(r.URL.Path, "/")
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+
+	require.Equal(t, "1", inFlight)
+	require.Equal(t, "1", itemsMap(s.m)["http_handler.users.status.2xx"])
+}
+
+func TestServerPanic(t *testing.T) {
+	s := NewServer("http_panic", 10, 16)
+
+	h := s.Wrap("panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	require.Panics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+
+	items := itemsMap(s.m)
+	require.Equal(t, "1", items["http_panic.panic.status.5xx"])
+	require.Equal(t, "0", items["http_panic.panic.in_flight"])
+}
+
+func TestServerRouteSanitized(t *testing.T) {
+	s := NewServer("http_sanitized", 10, 16)
+
+	h := s.Handler(http.NotFoundHandler(), func(r *http.Request) string { return "GET /items/{id}" })
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/1", nil))
+
+	require.Equal(t, "1", itemsMap(s.m)["http_sanitized.GET__items__id_.status.4xx"])
+}
+
+func TestServerFlushHijack(t *testing.T) {
+	s := NewServer("http_flush_hijack", 10, 16)
+
+	var flusher, hijacker bool
+	h := s.Wrap("ws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+
+		if r.URL.Path == "/flush" {
+			w.(http.Flusher).Flush()
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+	}))
+
+	// httptest.ResponseRecorder only implements http.Flusher.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/flush", nil))
+	require.True(t, flusher)
+	require.False(t, hijacker)
+	require.True(t, rec.Flushed)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/hijack")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.True(t, flusher)
+	require.True(t, hijacker)
+
+	// The request is recorded once the handler returns, which can be after the client got the response.
+	require.Eventually(t, func() bool {
+		return itemsMap(s.m)["http_flush_hijack.ws.status.1xx"] == "1"
+	}, time.Second, time.Millisecond)
+	require.Equal(t, "1", itemsMap(s.m)["http_flush_hijack.ws.status.2xx"])
+}
@@ -0,0 +1,264 @@
+// Package mgrrpc records metrics about gRPC calls into mgr variables.
+//
+// It provides unary and streaming interceptors for servers and clients:
+//
+//	m := mgrrpc.NewMetrics("grpc.server", 100, 1024)
+//	s := grpc.NewServer(
+//		grpc.UnaryInterceptor(m.UnaryServerInterceptor),
+//		grpc.StreamInterceptor(m.StreamServerInterceptor),
+//	)
+//
+// This package is a separate module so that only its users depend on google.golang.org/grpc,
+// the mgr package stays dependency-free.
+package mgrrpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	mgr "github.com/vrischmann/mgraphite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OtherMethod is the method under which calls are recorded once the maximum number of methods is reached.
+const OtherMethod = "other"
+
+// CodeFunc returns the status code of a call given the error it returned, nil meaning success.
+type CodeFunc func(err error) codes.Code
+
+// DefaultCode is the CodeFunc used unless configured otherwise.
+// It returns the code of the gRPC status of the error, see status.Code.
+func DefaultCode(err error) codes.Code { return status.Code(err) }
+
+// Metrics records metrics about gRPC calls through its interceptors.
+//
+// The metrics of each method are reported under the key of the variable followed by the method name,
+// where the method "/pkg.Service/Method" becomes "pkg_Service.Method":
+//
+//	<name>.<method>.started
+//	<name>.<method>.handled.<code>
+//	<name>.<method>.latency.<stat>
+//
+// The codes are the names of the gRPC codes, like OK or NotFound. The latency is in nanoseconds,
+// for streams it is the duration of the whole stream.
+type Metrics struct {
+	m          *mgr.Map
+	maxMethods int
+	bufferSize int
+
+	// mu protects code and methods, the existing methods are looked up with a read lock.
+	mu      sync.RWMutex
+	code    CodeFunc
+	methods map[string]*methodMetrics
+}
+
+type methodMetrics struct {
+	started mgr.Int
+	latency *mgr.Histogram
+
+	// mu protects codes, the existing codes are looked up with a read lock.
+	mu      sync.RWMutex
+	handled *mgr.Map
+	codes   map[codes.Code]*mgr.Int
+}
+
+// NewMetrics creates a Metrics and publishes its metrics under `name`.
+//
+// At most `maxMethods` methods are recorded, the calls of the methods seen afterwards are recorded under OtherMethod.
+// `bufferSize` is the buffer size of the latency histogram of each method.
+func NewMetrics(name string, maxMethods, bufferSize int) *Metrics {
+	return &Metrics{
+		m:          mgr.NewMap(name),
+		maxMethods: maxMethods,
+		bufferSize: bufferSize,
+		code:       DefaultCode,
+		methods:    make(map[string]*methodMetrics),
+	}
+}
+
+// Code sets the function returning the status code of a call, for example to handle errors
+// not carrying a gRPC status. Must be called before making calls.
+func (m *Metrics) Code(fn CodeFunc) *Metrics {
+	m.mu.Lock()
+	m.code = fn
+	m.mu.Unlock()
+
+	return m
+}
+
+// methodKey converts a full method name to a Graphite key made of the service and the method nodes.
+func methodKey(fullMethod string) string {
+	service, method := "", strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndexByte(method, '/'); i >= 0 {
+		service, method = method[:i], method[i+1:]
+	}
+
+	return mgr.JoinKey(mgr.SanitizeNode(service), mgr.SanitizeNode(method))
+}
+
+// method returns the metrics of the method `fullMethod`, creating them if needed.
+func (m *Metrics) method(fullMethod string) (*methodMetrics, CodeFunc) {
+	name := methodKey(fullMethod)
+
+	m.mu.RLock()
+	mm, ok := m.methods[name]
+	if !ok && len(m.methods) >= m.maxMethods {
+		mm, ok = m.methods[OtherMethod]
+	}
+	code := m.code
+	m.mu.RUnlock()
+	if ok {
+		return mm, code
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mm, ok := m.methods[name]; ok {
+		return mm, m.code
+	}
+	if len(m.methods) >= m.maxMethods && name != OtherMethod {
+		if mm, ok := m.methods[OtherMethod]; ok {
+			return mm, m.code
+		}
+		name = OtherMethod
+	}
+
+	mm = &methodMetrics{
+		latency: new(mgr.Histogram).Init(m.bufferSize),
+		handled: new(mgr.Map).Init(),
+		codes:   make(map[codes.Code]*mgr.Int),
+	}
+
+	v := new(mgr.Map).Init()
+	v.Set("started", &mm.started)
+	v.Set("handled", mm.handled)
+	v.Set("latency", mm.latency)
+
+	m.methods[name] = mm
+	m.m.Set(name, v)
+
+	return mm, m.code
+}
+
+// done records the end of a call started at `start`.
+func (mm *methodMetrics) done(code codes.Code, start time.Time) {
+	mm.latency.RecordSince(start)
+
+	mm.mu.RLock()
+	i, ok := mm.codes[code]
+	mm.mu.RUnlock()
+
+	if !ok {
+		mm.mu.Lock()
+		if i, ok = mm.codes[code]; !ok {
+			i = new(mgr.Int)
+			mm.codes[code] = i
+			mm.handled.Set(code.String(), i)
+		}
+		mm.mu.Unlock()
+	}
+
+	i.Add(1)
+}
+
+// UnaryServerInterceptor records the unary calls handled by a server.
+func (m *Metrics) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	mm, code := m.method(info.FullMethod)
+
+	start := time.Now()
+	mm.started.Add(1)
+
+	resp, err := handler(ctx, req)
+	mm.done(code(err), start)
+
+	return resp, err
+}
+
+// StreamServerInterceptor records the streams handled by a server.
+func (m *Metrics) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	mm, code := m.method(info.FullMethod)
+
+	start := time.Now()
+	mm.started.Add(1)
+
+	err := handler(srv, ss)
+	mm.done(code(err), start)
+
+	return err
+}
+
+// UnaryClientInterceptor records the unary calls made by a client.
+func (m *Metrics) UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	mm, code := m.method(method)
+
+	start := time.Now()
+	mm.started.Add(1)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	mm.done(code(err), start)
+
+	return err
+}
+
+// StreamClientInterceptor records the streams opened by a client.
+//
+// A stream is done when it couldn't be opened or when RecvMsg returns an error, io.EOF meaning success.
+// If the server doesn't stream its response, the stream is also done once the response is received.
+func (m *Metrics) StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	mm, code := m.method(method)
+
+	start := time.Now()
+	mm.started.Add(1)
+
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		mm.done(code(err), start)
+		return nil, err
+	}
+
+	return &clientStream{
+		ClientStream:  cs,
+		serverStreams: desc.ServerStreams,
+		mm:            mm,
+		code:          code,
+		start:         start,
+	}, nil
+}
+
+// clientStream records the end of a client stream.
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	mm            *methodMetrics
+	code          CodeFunc
+	start         time.Time
+	once          sync.Once
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.once.Do(func() { s.mm.done(s.code(nil), s.start) })
+	case err != nil:
+		s.once.Do(func() { s.mm.done(s.code(err), s.start) })
+	case !s.serverStreams:
+		// The single response of the server ends the call.
+		s.once.Do(func() { s.mm.done(s.code(nil), s.start) })
+	}
+
+	return err
+}
+
+var (
+	_ grpc.UnaryServerInterceptor  = (*Metrics)(nil).UnaryServerInterceptor
+	_ grpc.StreamServerInterceptor = (*Metrics)(nil).StreamServerInterceptor
+	_ grpc.UnaryClientInterceptor  = (*Metrics)(nil).UnaryClientInterceptor
+	_ grpc.StreamClientInterceptor = (*Metrics)(nil).StreamClientInterceptor
+)
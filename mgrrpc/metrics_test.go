@@ -0,0 +1,232 @@
+package mgrrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func itemsMap(m *Metrics) map[string]string {
+	res := make(map[string]string)
+	for _, item := range m.m.Items() {
+		res[item.Key] = item.Value
+	}
+	return res
+}
+
+func TestMethodKey(t *testing.T) {
+	require.Equal(t, "helloworld_Greeter.SayHello", methodKey("/helloworld.Greeter/SayHello"))
+	require.Equal(t, "Service.Method", methodKey("Service/Method"))
+	require.Equal(t, "Method", methodKey("Method"))
+}
+
+// testService is a service registered without generated code:
+// Echo is unary, Count is server streaming and Sum is client streaming.
+var testService = grpc.ServiceDesc{
+	ServiceName: "mgrrpc.Test",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				s := req.(*wrapperspb.StringValue).Value
+				if s == "" {
+					return nil, status.Error(codes.InvalidArgument, "empty string")
+				}
+				return wrapperspb.String(s), nil
+			}
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+			return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/mgrrpc.Test/Echo"}, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Count",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				n := new(wrapperspb.Int64Value)
+				if err := stream.RecvMsg(n); err != nil {
+					return err
+				}
+				for i := int64(0); i < n.Value; i++ {
+					if err := stream.SendMsg(wrapperspb.Int64(i)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			StreamName:    "Sum",
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				var sum int64
+				for {
+					n := new(wrapperspb.Int64Value)
+					err := stream.RecvMsg(n)
+					if err == io.EOF {
+						return stream.SendMsg(wrapperspb.Int64(sum))
+					}
+					if err != nil {
+						return err
+					}
+					sum += n.Value
+				}
+			},
+		},
+	},
+}
+
+var (
+	countDesc = &testService.Streams[0]
+	sumDesc   = &testService.Streams[1]
+)
+
+// startServer starts an in-process server instrumented by `server` and returns a client instrumented by `client`.
+func startServer(t *testing.T, server, client *Metrics) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(server.UnaryServerInterceptor),
+		grpc.StreamInterceptor(server.StreamServerInterceptor),
+	)
+	s.RegisterService(&testService, struct{}{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(client.UnaryClientInterceptor),
+		grpc.WithStreamInterceptor(client.StreamClientInterceptor),
+	)
+	require.Nil(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	return cc
+}
+
+func TestUnary(t *testing.T) {
+	server := NewMetrics("rpc_unary_server", 10, 16)
+	client := NewMetrics("rpc_unary_client", 10, 16)
+	cc := startServer(t, server, client)
+
+	ctx := context.Background()
+	for _, s := range []string{"hello", "world", ""} {
+		reply := new(wrapperspb.StringValue)
+		err := cc.Invoke(ctx, "/mgrrpc.Test/Echo", wrapperspb.String(s), reply)
+		if s == "" {
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		} else {
+			require.Nil(t, err)
+			require.Equal(t, s, reply.Value)
+		}
+	}
+
+	for name, m := range map[string]*Metrics{"rpc_unary_server": server, "rpc_unary_client": client} {
+		items := itemsMap(m)
+
+		require.Equal(t, "3", items[name+".mgrrpc_Test.Echo.started"])
+		require.Equal(t, "2", items[name+".mgrrpc_Test.Echo.handled.OK"])
+		require.Equal(t, "1", items[name+".mgrrpc_Test.Echo.handled.InvalidArgument"])
+		require.Contains(t, items, name+".mgrrpc_Test.Echo.latency.p99")
+	}
+}
+
+func TestServerStreaming(t *testing.T) {
+	server := NewMetrics("rpc_server_streaming_server", 10, 16)
+	client := NewMetrics("rpc_server_streaming_client", 10, 16)
+	cc := startServer(t, server, client)
+
+	cs, err := cc.NewStream(context.Background(), countDesc, "/mgrrpc.Test/Count")
+	require.Nil(t, err)
+	require.Nil(t, cs.SendMsg(wrapperspb.Int64(3)))
+	require.Nil(t, cs.CloseSend())
+
+	for i := int64(0); i < 3; i++ {
+		n := new(wrapperspb.Int64Value)
+		require.Nil(t, cs.RecvMsg(n))
+		require.Equal(t, i, n.Value)
+
+		// The stream isn't done until the server ends it.
+		require.NotContains(t, itemsMap(client), "rpc_server_streaming_client.mgrrpc_Test.Count.handled.OK")
+	}
+	require.Equal(t, io.EOF, cs.RecvMsg(new(wrapperspb.Int64Value)))
+
+	require.Equal(t, "1", itemsMap(client)["rpc_server_streaming_client.mgrrpc_Test.Count.handled.OK"])
+	require.Equal(t, "1", itemsMap(server)["rpc_server_streaming_server.mgrrpc_Test.Count.handled.OK"])
+}
+
+func TestClientStreaming(t *testing.T) {
+	server := NewMetrics("rpc_client_streaming_server", 10, 16)
+	client := NewMetrics("rpc_client_streaming_client", 10, 16)
+	cc := startServer(t, server, client)
+
+	cs, err := cc.NewStream(context.Background(), sumDesc, "/mgrrpc.Test/Sum")
+	require.Nil(t, err)
+	for i := int64(1); i <= 3; i++ {
+		require.Nil(t, cs.SendMsg(wrapperspb.Int64(i)))
+	}
+
+	// Like the generated CloseAndRecv, which gets a single response with a nil error.
+	require.Nil(t, cs.CloseSend())
+	sum := new(wrapperspb.Int64Value)
+	require.Nil(t, cs.RecvMsg(sum))
+	require.Equal(t, int64(6), sum.Value)
+
+	items := itemsMap(client)
+	require.Equal(t, "1", items["rpc_client_streaming_client.mgrrpc_Test.Sum.started"])
+	require.Equal(t, "1", items["rpc_client_streaming_client.mgrrpc_Test.Sum.handled.OK"])
+	require.NotEqual(t, "0", items["rpc_client_streaming_client.mgrrpc_Test.Sum.latency.max"])
+}
+
+func TestStreamClientError(t *testing.T) {
+	client := NewMetrics("rpc_stream_error", 10, 16).Code(func(err error) codes.Code {
+		if errors.Is(err, context.Canceled) {
+			return codes.Canceled
+		}
+		return status.Code(err)
+	})
+	cc := startServer(t, NewMetrics("rpc_stream_error_server", 10, 16), client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cc.NewStream(ctx, countDesc, "/mgrrpc.Test/Count")
+	require.NotNil(t, err)
+
+	items := itemsMap(client)
+	require.Equal(t, "1", items["rpc_stream_error.mgrrpc_Test.Count.started"])
+	require.Equal(t, "1", items["rpc_stream_error.mgrrpc_Test.Count.handled.Canceled"])
+}
+
+func TestMaxMethods(t *testing.T) {
+	m := NewMetrics("rpc_max_methods", 1, 16)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil }
+	for _, method := range []string{"/pkg.Greeter/SayHello", "/pkg.Greeter/SayBye", "/pkg.Greeter/SayHi"} {
+		_, err := m.UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		require.Nil(t, err)
+	}
+
+	items := itemsMap(m)
+	require.Equal(t, "1", items["rpc_max_methods.pkg_Greeter.SayHello.handled.OK"])
+	require.Equal(t, "2", items["rpc_max_methods.other.handled.OK"])
+}
@@ -0,0 +1,63 @@
+// Package mgrsql records metrics about database/sql connection pools into mgr variables.
+package mgrsql
+
+import (
+	"database/sql"
+	"sync"
+
+	mgr "github.com/vrischmann/mgraphite"
+)
+
+// DBStats is a variable reporting the statistics of a database/sql connection pool that satisfies the Var interface.
+//
+// The current state of the pool is reported as gauges: max_open_connections, open_connections, in_use and idle.
+// The cumulative statistics are reported as their change since the previous export: wait_count,
+// wait_duration_ns, max_idle_closed, max_idle_time_closed and max_lifetime_closed.
+type DBStats struct {
+	key string
+	db  *sql.DB
+
+	mu   sync.Mutex
+	last sql.DBStats
+}
+
+// NewDBStats creates a DBStats reporting the statistics of `db` and publishes it.
+func NewDBStats(name string, db *sql.DB) *DBStats {
+	s := &DBStats{key: name, db: db}
+	mgr.Publish(s)
+
+	return s
+}
+
+func (s *DBStats) Items() []mgr.KeyValue { return mgr.SampleFunc(s.Samples).Items() }
+
+// Samples reads the statistics of the pool.
+func (s *DBStats) Samples() []mgr.Sample {
+	stats := s.db.Stats()
+
+	s.mu.Lock()
+	last := s.last
+	s.last = stats
+	s.mu.Unlock()
+
+	gauge := func(name string, v int64) mgr.Sample {
+		return mgr.Sample{Key: mgr.JoinKey(s.key, name), Value: mgr.Int64Value(v), Kind: mgr.KindGauge}
+	}
+
+	return []mgr.Sample{
+		gauge("max_open_connections", int64(stats.MaxOpenConnections)),
+		gauge("open_connections", int64(stats.OpenConnections)),
+		gauge("in_use", int64(stats.InUse)),
+		gauge("idle", int64(stats.Idle)),
+		gauge("wait_count", stats.WaitCount-last.WaitCount),
+		gauge("wait_duration_ns", int64(stats.WaitDuration-last.WaitDuration)),
+		gauge("max_idle_closed", stats.MaxIdleClosed-last.MaxIdleClosed),
+		gauge("max_idle_time_closed", stats.MaxIdleTimeClosed-last.MaxIdleTimeClosed),
+		gauge("max_lifetime_closed", stats.MaxLifetimeClosed-last.MaxLifetimeClosed),
+	}
+}
+
+var (
+	_ mgr.Var     = (*DBStats)(nil)
+	_ mgr.Sampler = (*DBStats)(nil)
+)
@@ -0,0 +1,93 @@
+package mgrsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver is a database/sql driver whose connections can't run anything,
+// which is enough to exercise the connection pool.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("mgrsql_fake", fakeDriver{})
+}
+
+func itemsMap(s *DBStats) map[string]string {
+	res := make(map[string]string)
+	for _, item := range s.Items() {
+		res[item.Key] = item.Value
+	}
+	return res
+}
+
+func TestDBStats(t *testing.T) {
+	db, err := sql.Open("mgrsql_fake", "")
+	require.Nil(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	s := NewDBStats("db.main", db)
+
+	ctx := context.Background()
+	c1, err := db.Conn(ctx)
+	require.Nil(t, err)
+
+	items := itemsMap(s)
+	require.Equal(t, "1", items["db.main.max_open_connections"])
+	require.Equal(t, "1", items["db.main.open_connections"])
+	require.Equal(t, "1", items["db.main.in_use"])
+	require.Equal(t, "0", items["db.main.idle"])
+	require.Equal(t, "0", items["db.main.wait_count"])
+
+	// Wait for a second connection while the only one is in use.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c2, err := db.Conn(ctx)
+		if err == nil {
+			c2.Close()
+		}
+	}()
+	for db.Stats().WaitCount == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c1.Close()
+	<-done
+
+	items = itemsMap(s)
+	require.Equal(t, "0", items["db.main.in_use"])
+	require.Equal(t, "1", items["db.main.idle"])
+	require.Equal(t, "1", items["db.main.wait_count"])
+	require.NotEqual(t, "0", items["db.main.wait_duration_ns"])
+
+	// The cumulative statistics are reported as deltas.
+	items = itemsMap(s)
+	require.Equal(t, "0", items["db.main.wait_count"])
+	require.Equal(t, "0", items["db.main.wait_duration_ns"])
+}
+
+func TestDBStatsEmptyKey(t *testing.T) {
+	db, err := sql.Open("mgrsql_fake", "")
+	require.Nil(t, err)
+	defer db.Close()
+
+	s := &DBStats{db: db}
+
+	require.Equal(t, "max_open_connections", s.Samples()[0].Key)
+}
@@ -0,0 +1,103 @@
+package mgr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var hostnameFn = os.Hostname
+
+// ExpandPrefix expands the placeholders of a prefix template.
+//
+// The supported placeholders are:
+//
+//	{hostname}        the hostname, as a single node
+//	{hostname_short}  the hostname up to its first dot
+//	{fqdn_reversed}   the hostname with its labels reversed, for example com.example.web1
+//	{pid}             the process ID
+//	{env:NAME}        the value of the environment variable NAME
+//
+// Each expanded value is sanitized, so that for example a dotted hostname doesn't create extra
+// Graphite nodes: every character other than an ASCII letter, a digit, '-' or '_' is replaced by '_'.
+// Config.Prefix is expanded by Export, so that a prefix like "{env:ENV}.{env:SERVICE}.{hostname_short}" can be used directly.
+func ExpandPrefix(template string) (string, error) {
+	var buf strings.Builder
+
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			buf.WriteString(template)
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q", template)
+		}
+		end += start
+
+		value, err := expandPlaceholder(template[start+1 : end])
+		if err != nil {
+			return "", err
+		}
+
+		buf.WriteString(template[:start])
+		buf.WriteString(value)
+		template = template[end+1:]
+	}
+
+	return buf.String(), nil
+}
+
+func expandPlaceholder(name string) (string, error) {
+	if strings.HasPrefix(name, "env:") {
+		return SanitizeNode(os.Getenv(strings.TrimPrefix(name, "env:"))), nil
+	}
+
+	switch name {
+	case "pid":
+		return strconv.Itoa(os.Getpid()), nil
+	case "hostname", "hostname_short", "fqdn_reversed":
+	default:
+		return "", fmt.Errorf("unknown placeholder {%s}", name)
+	}
+
+	hostname, err := hostnameFn()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the hostname: %v", err)
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+
+	switch name {
+	case "hostname":
+		return SanitizeNode(hostname), nil
+	case "hostname_short":
+		if i := strings.IndexByte(hostname, '.'); i >= 0 {
+			hostname = hostname[:i]
+		}
+		return SanitizeNode(hostname), nil
+	default:
+		labels := strings.Split(hostname, ".")
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		for i, label := range labels {
+			labels[i] = SanitizeNode(label)
+		}
+		return strings.Join(labels, "."), nil
+	}
+}
+
+// SanitizeNode makes `s` usable as a single Graphite node by replacing every character
+// other than ASCII letters, digits, dashes and underscores with an underscore.
+func SanitizeNode(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
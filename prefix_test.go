@@ -0,0 +1,64 @@
+package mgr
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPrefix(t *testing.T) {
+	hostnameFn = func() (string, error) { return "web1.eu-west.example.com", nil }
+	defer func() { hostnameFn = os.Hostname }()
+
+	t.Setenv("ENV", "prod")
+	t.Setenv("SERVICE", "checkout.api")
+
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{"", ""},
+		{"foo.bar", "foo.bar"},
+		{"{hostname}", "web1_eu-west_example_com"},
+		{"{env:ENV}.{env:SERVICE}.{hostname_short}", "prod.checkout_api.web1"},
+		{"servers.{fqdn_reversed}", "servers.com.example.eu-west.web1"},
+		{"app.{pid}", "app." + strconv.Itoa(os.Getpid())},
+		{"{env:MGR_TEST_UNSET}.foo", ".foo"},
+	}
+
+	for _, tc := range testCases {
+		res, err := ExpandPrefix(tc.template)
+		require.Nil(t, err, tc.template)
+		require.Equal(t, tc.expected, res, tc.template)
+	}
+}
+
+func TestExpandPrefixErrors(t *testing.T) {
+	_, err := ExpandPrefix("{host}")
+	require.Equal(t, "unknown placeholder {host}", err.Error())
+
+	_, err = ExpandPrefix("{hostname")
+	require.Equal(t, `unterminated placeholder in "{hostname"`, err.Error())
+
+	hostnameFn = func() (string, error) { return "", errors.New("no hostname") }
+	defer func() { hostnameFn = os.Hostname }()
+
+	_, err = ExpandPrefix("{hostname}")
+	require.Equal(t, "unable to get the hostname: no hostname", err.Error())
+}
+
+func TestConfigPrefixTemplate(t *testing.T) {
+	hostnameFn = func() (string, error) { return "web1.example.com", nil }
+	defer func() { hostnameFn = os.Hostname }()
+
+	config := &Config{Addr: "localhost:2003", Prefix: "prod.{hostname_short}"}
+	require.Nil(t, config.Validate())
+	require.Equal(t, "prod.web1", config.withDefaults().Prefix)
+	require.Equal(t, "prod.{hostname_short}", config.Prefix)
+
+	config.Prefix = "{env:MGR_TEST_UNSET}.foo"
+	require.Equal(t, `invalid config: invalid prefix "{env:MGR_TEST_UNSET}.foo": contains an empty node`, config.Validate().Error())
+}
@@ -0,0 +1,176 @@
+package mgr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// userHZ is the number of clock ticks per second used by /proc/<pid>/stat, which is 100 on every Linux architecture.
+const userHZ = 100
+
+// ProcessStats is a variable reporting the resource usage of the current process on Linux that satisfies the Var interface.
+//
+// It reads /proc/self/stat, /proc/self/status, /proc/self/limits, /proc/self/fd and /proc/self/io
+// and reports the CPU time, memory, file descriptors, context switches and I/O of the process.
+// The files which can't be read, for example /proc/self/io in some containers or /proc
+// entirely on other operating systems, are skipped.
+type ProcessStats struct {
+	key  string
+	root string
+}
+
+// NewProcessStats creates a ProcessStats and publishes it.
+func NewProcessStats(name string) *ProcessStats {
+	p := &ProcessStats{key: name, root: "/proc/self"}
+	Publish(p)
+
+	return p
+}
+
+func (p *ProcessStats) Items() []KeyValue { return formatSamples(p.Samples()) }
+
+// Samples reads the resource usage of the process.
+func (p *ProcessStats) Samples() []Sample {
+	var res []Sample
+
+	add := func(name string, v Value, kind Kind) {
+		res = append(res, Sample{Key: JoinKey(p.key, name), Value: v, Kind: kind})
+	}
+
+	if stat, err := readProcStat(filepath.Join(p.root, "stat")); err == nil {
+		add("cpu.user_seconds", Float64Value(float64(stat.utime)/userHZ), KindCounter)
+		add("cpu.system_seconds", Float64Value(float64(stat.stime)/userHZ), KindCounter)
+	}
+
+	if status, err := readProcKeyValues(filepath.Join(p.root, "status"), ":"); err == nil {
+		if v, ok := status["VmRSS"]; ok {
+			add("memory.rss_bytes", Uint64Value(v*1024), KindGauge)
+		}
+		if v, ok := status["VmSize"]; ok {
+			add("memory.virtual_bytes", Uint64Value(v*1024), KindGauge)
+		}
+		if v, ok := status["voluntary_ctxt_switches"]; ok {
+			add("ctx_switches.voluntary", Uint64Value(v), KindCounter)
+		}
+		if v, ok := status["nonvoluntary_ctxt_switches"]; ok {
+			add("ctx_switches.involuntary", Uint64Value(v), KindCounter)
+		}
+	}
+
+	if fds, err := os.ReadDir(filepath.Join(p.root, "fd")); err == nil {
+		add("fds.open", Int64Value(int64(len(fds))), KindGauge)
+	}
+	if max, err := readProcMaxFDs(filepath.Join(p.root, "limits")); err == nil {
+		add("fds.max", Uint64Value(max), KindGauge)
+	}
+
+	if io, err := readProcKeyValues(filepath.Join(p.root, "io"), ":"); err == nil {
+		for _, name := range []string{"rchar", "wchar", "read_bytes", "write_bytes"} {
+			if v, ok := io[name]; ok {
+				add("io."+name, Uint64Value(v), KindCounter)
+			}
+		}
+	}
+
+	return res
+}
+
+type procStat struct {
+	utime uint64
+	stime uint64
+}
+
+// readProcStat parses /proc/<pid>/stat, see proc(5).
+func readProcStat(path string) (res procStat, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return res, err
+	}
+
+	// The command name is in parentheses and can contain spaces and parentheses itself,
+	// the fields start after the last closing parenthesis with the state (field 3).
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return res, fmt.Errorf("%s: malformed stat", path)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 13 {
+		return res, fmt.Errorf("%s: malformed stat", path)
+	}
+
+	if res.utime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return res, err
+	}
+	if res.stime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// readProcKeyValues parses a file made of "<key><sep> <value> [unit]" lines, like /proc/<pid>/status.
+// Lines whose value isn't an unsigned integer are ignored.
+func readProcKeyValues(path, sep string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := make(map[string]uint64)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		i := strings.Index(line, sep)
+		if i < 0 {
+			continue
+		}
+
+		fields := strings.Fields(line[i+len(sep):])
+		if len(fields) == 0 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		res[line[:i]] = v
+	}
+
+	return res, scanner.Err()
+}
+
+// readProcMaxFDs returns the soft limit of open files from /proc/<pid>/limits.
+func readProcMaxFDs(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			break
+		}
+		return strconv.ParseUint(fields[0], 10, 64)
+	}
+
+	return 0, fmt.Errorf("%s: no open files limit", path)
+}
+
+var (
+	_ Var     = (*ProcessStats)(nil)
+	_ Sampler = (*ProcessStats)(nil)
+)
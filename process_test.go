@@ -0,0 +1,49 @@
+package mgr
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessStatsFixtures(t *testing.T) {
+	p := &ProcessStats{key: "process", root: "testdata/proc"}
+
+	require.Equal(t, []Sample{
+		{Key: "process.cpu.user_seconds", Value: Float64Value(12.34), Kind: KindCounter},
+		{Key: "process.cpu.system_seconds", Value: Float64Value(5.67), Kind: KindCounter},
+		{Key: "process.memory.rss_bytes", Value: Uint64Value(102400000), Kind: KindGauge},
+		{Key: "process.memory.virtual_bytes", Value: Uint64Value(1548288000), Kind: KindGauge},
+		{Key: "process.ctx_switches.voluntary", Value: Uint64Value(1500), Kind: KindCounter},
+		{Key: "process.ctx_switches.involuntary", Value: Uint64Value(42), Kind: KindCounter},
+		{Key: "process.fds.open", Value: Int64Value(4), Kind: KindGauge},
+		{Key: "process.fds.max", Value: Uint64Value(1024), Kind: KindGauge},
+		{Key: "process.io.rchar", Value: Uint64Value(3980), Kind: KindCounter},
+		{Key: "process.io.wchar", Value: Uint64Value(1200), Kind: KindCounter},
+		{Key: "process.io.read_bytes", Value: Uint64Value(40960), Kind: KindCounter},
+		{Key: "process.io.write_bytes", Value: Uint64Value(8192), Kind: KindCounter},
+	}, p.Samples())
+}
+
+func TestProcessStatsMissing(t *testing.T) {
+	p := &ProcessStats{key: "process", root: "testdata/nonexistent"}
+	require.Empty(t, p.Samples())
+}
+
+func TestProcessStats(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc is only available on Linux")
+	}
+
+	p := NewProcessStats("process")
+
+	keys := make(map[string]bool)
+	for _, s := range p.Samples() {
+		keys[s.Key] = true
+	}
+
+	require.True(t, keys["process.cpu.user_seconds"])
+	require.True(t, keys["process.memory.rss_bytes"])
+	require.True(t, keys["process.fds.open"])
+}
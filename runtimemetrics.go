@@ -0,0 +1,193 @@
+package mgr
+
+import (
+	"math"
+	"path"
+	"runtime/metrics"
+	"strings"
+	"sync"
+)
+
+// DefaultRuntimePercentiles are the percentiles reported for the runtime histograms unless configured otherwise.
+var DefaultRuntimePercentiles = []float64{50, 90, 99}
+
+// RuntimeMetrics is a variable reporting the metrics of the runtime/metrics package that satisfies the Var interface.
+//
+// Unlike MemStats, reading these metrics doesn't stop the world.
+// Metric names are converted to Graphite paths: "/gc/heap/allocs:bytes" is reported as "gc.heap.allocs_bytes".
+// Histograms, like "/sched/latencies:seconds", are reported as a count and percentiles of the
+// values observed since the previous export.
+type RuntimeMetrics struct {
+	key string
+
+	mu          sync.Mutex
+	samples     []metrics.Sample
+	names       []string
+	cumulative  []bool
+	percentiles []float64
+	// previous holds the bucket counts of the histograms as of the previous export.
+	previous map[string][]uint64
+}
+
+// NewRuntimeMetrics creates a RuntimeMetrics and publishes it.
+// See Init for the meaning of patterns.
+func NewRuntimeMetrics(name string, patterns ...string) *RuntimeMetrics {
+	r := &RuntimeMetrics{key: name}
+	r.Init(patterns...)
+	Publish(r)
+
+	return r
+}
+
+// Init selects the metrics whose name match one of the patterns, using the path.Match syntax,
+// for example "/gc/*" or "/sched/latencies:seconds". If no pattern is given, every supported metric is selected.
+// Note that NewRuntimeMetrics already initializes the variable.
+func (r *RuntimeMetrics) Init(patterns ...string) *RuntimeMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.samples = nil
+	r.names = nil
+	r.cumulative = nil
+	r.percentiles = DefaultRuntimePercentiles
+	r.previous = make(map[string][]uint64)
+
+	for _, desc := range metrics.All() {
+		if desc.Kind == metrics.KindBad || !matchAny(patterns, desc.Name) {
+			continue
+		}
+
+		r.samples = append(r.samples, metrics.Sample{Name: desc.Name})
+		r.names = append(r.names, runtimeMetricKey(desc.Name))
+		r.cumulative = append(r.cumulative, desc.Cumulative)
+	}
+
+	return r
+}
+
+// Configure sets the percentiles reported for the histograms.
+// Like Histogram.Configure, it panics if a percentile is out of range or if two percentiles have the same name.
+func (r *RuntimeMetrics) Configure(percentiles ...float64) *RuntimeMetrics {
+	checkPercentiles(percentiles)
+
+	r.mu.Lock()
+	r.percentiles = append([]float64(nil), percentiles...)
+	r.mu.Unlock()
+
+	return r
+}
+
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeMetricKey converts a runtime/metrics name to a Graphite path.
+func runtimeMetricKey(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.Replace(name, ":", "_", -1)
+
+	nodes := strings.Split(name, "/")
+	for i, node := range nodes {
+		nodes[i] = SanitizeNode(node)
+	}
+
+	return strings.Join(nodes, ".")
+}
+
+func (r *RuntimeMetrics) Items() []KeyValue { return formatSamples(r.Samples()) }
+
+// Samples reads the selected metrics.
+func (r *RuntimeMetrics) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics.Read(r.samples)
+
+	var res []Sample
+	for i, s := range r.samples {
+		key := JoinKey(r.key, r.names[i])
+		kind := KindGauge
+		if r.cumulative[i] {
+			kind = KindCounter
+		}
+
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			res = append(res, Sample{Key: key, Value: Uint64Value(s.Value.Uint64()), Kind: kind})
+		case metrics.KindFloat64:
+			res = append(res, Sample{Key: key, Value: Float64Value(s.Value.Float64()), Kind: kind})
+		case metrics.KindFloat64Histogram:
+			res = append(res, r.histogramSamples(key, s.Name, s.Value.Float64Histogram())...)
+		}
+	}
+
+	return res
+}
+
+// histogramSamples returns the count and percentiles of the values observed since the previous export.
+func (r *RuntimeMetrics) histogramSamples(key, name string, h *metrics.Float64Histogram) []Sample {
+	previous := r.previous[name]
+
+	counts := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, c := range h.Counts {
+		counts[i] = c
+		if i < len(previous) {
+			counts[i] -= previous[i]
+		}
+		total += counts[i]
+	}
+	r.previous[name] = append(previous[:0], h.Counts...)
+
+	res := []Sample{{Key: JoinKey(key, "count"), Value: Uint64Value(total), Kind: KindGauge}}
+	if total == 0 {
+		return res
+	}
+
+	for _, p := range r.percentiles {
+		res = append(res, Sample{
+			Key:   JoinKey(key, percentileName(p)),
+			Value: Float64Value(bucketPercentile(h.Buckets, counts, total, p)),
+			Kind:  KindGauge,
+		})
+	}
+
+	return res
+}
+
+// bucketPercentile returns the upper boundary of the bucket holding the percentile p.
+// If that boundary is infinite, the lower one is returned instead.
+func bucketPercentile(buckets []float64, counts []uint64, total uint64, p float64) float64 {
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		if cumulative < rank {
+			continue
+		}
+
+		if upper := buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return buckets[i]
+	}
+
+	return buckets[len(buckets)-1]
+}
+
+var (
+	_ Var     = (*RuntimeMetrics)(nil)
+	_ Sampler = (*RuntimeMetrics)(nil)
+)
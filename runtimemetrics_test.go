@@ -0,0 +1,80 @@
+package mgr
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetricKey(t *testing.T) {
+	require.Equal(t, "gc.heap.allocs_bytes", runtimeMetricKey("/gc/heap/allocs:bytes"))
+	require.Equal(t, "sched.goroutines_goroutines", runtimeMetricKey("/sched/goroutines:goroutines"))
+	require.Equal(t, "gc.heap.allocs-by-size_bytes", runtimeMetricKey("/gc/heap/allocs-by-size:bytes"))
+	require.Equal(t, "godebug.non-default-behavior.x509sha1_events", runtimeMetricKey("/godebug/non-default-behavior/x509sha1:events"))
+	require.Equal(t, "cpu.classes.gc.mark.assist_cpu-seconds", runtimeMetricKey("/cpu/classes/gc/mark/assist:cpu-seconds"))
+}
+
+func TestBucketPercentile(t *testing.T) {
+	buckets := []float64{0, 1, 2, 4, math.Inf(1)}
+	counts := []uint64{10, 80, 9, 1}
+
+	require.Equal(t, float64(1), bucketPercentile(buckets, counts, 100, 10))
+	require.Equal(t, float64(2), bucketPercentile(buckets, counts, 100, 50))
+	require.Equal(t, float64(4), bucketPercentile(buckets, counts, 100, 99))
+	require.Equal(t, float64(4), bucketPercentile(buckets, counts, 100, 100))
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	var r RuntimeMetrics
+	r.key = "runtime"
+	r.Init("/sched/goroutines:goroutines", "/gc/pauses:seconds", "/sched/pauses/total/gc:seconds")
+
+	runtime.GC()
+
+	samples := r.Samples()
+
+	var keys []string
+	for _, s := range samples {
+		keys = append(keys, s.Key)
+		require.True(t, strings.HasPrefix(s.Key, "runtime."))
+	}
+	require.Contains(t, keys, "runtime.sched.goroutines_goroutines")
+
+	found := false
+	for _, s := range samples {
+		if strings.HasSuffix(s.Key, "pauses_seconds.count") || strings.HasSuffix(s.Key, "gc_seconds.count") {
+			found = true
+			require.True(t, s.Value.Uint64() > 0)
+		}
+	}
+	require.True(t, found)
+
+}
+
+func TestRuntimeMetricsHistogramDelta(t *testing.T) {
+	var r RuntimeMetrics
+	r.Init("none").Configure(50)
+
+	h := &metrics.Float64Histogram{
+		Buckets: []float64{0, 1, 2, math.Inf(1)},
+		Counts:  []uint64{1, 1, 0},
+	}
+	require.Equal(t, []Sample{
+		{Key: "h.count", Value: Uint64Value(2), Kind: KindGauge},
+		{Key: "h.p50", Value: Float64Value(1), Kind: KindGauge},
+	}, r.histogramSamples("h", "/h:seconds", h))
+
+	h.Counts = []uint64{1, 1, 3}
+	require.Equal(t, []Sample{
+		{Key: "h.count", Value: Uint64Value(3), Kind: KindGauge},
+		{Key: "h.p50", Value: Float64Value(2), Kind: KindGauge},
+	}, r.histogramSamples("h", "/h:seconds", h))
+
+	require.Equal(t, []Sample{
+		{Key: "h.count", Value: Uint64Value(0), Kind: KindGauge},
+	}, r.histogramSamples("h", "/h:seconds", h))
+}
@@ -0,0 +1,277 @@
+package mgr
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheLinePad prevents false sharing between adjacent shards.
+type cacheLinePad [64]byte
+
+// numShards returns the number of shards used by the sharded variables:
+// GOMAXPROCS rounded up to a power of two.
+func numShards() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+	return n
+}
+
+// shardIndex picks a shard for the current write.
+// Go doesn't expose the current CPU, however the global math/rand source is
+// per-thread and lock-free, which spreads concurrent writers across shards about as well.
+func shardIndex(mask int) int {
+	return int(rand.Uint32()) & mask
+}
+
+type intShard struct {
+	v int64
+	_ cacheLinePad
+}
+
+// ShardedInt is a 64-bit integer variable that satisfies the Var interface.
+//
+// It behaves like Int but spreads writes across multiple shards combined in Items,
+// which reduces contention for very hot counters at the expense of more memory
+// and a more expensive read. Like Int, its value is reported as untyped.
+type ShardedInt struct {
+	key    string
+	shards []intShard
+}
+
+// NewShardedInt creates a ShardedInt and publishes it.
+func NewShardedInt(name string) *ShardedInt {
+	i := &ShardedInt{key: name}
+	i.Init()
+	Publish(i)
+
+	return i
+}
+
+// Init initializes the shards. Must be called before attempting to set a value.
+// Note that NewShardedInt already initializes the shards.
+func (i *ShardedInt) Init() *ShardedInt {
+	i.shards = make([]intShard, numShards())
+	return i
+}
+
+// Add atomically adds `delta` to the value.
+func (i *ShardedInt) Add(delta int64) {
+	atomic.AddInt64(&i.shards[shardIndex(len(i.shards)-1)].v, delta)
+}
+
+// Set sets the value to `val`.
+// Unlike Add, it is not atomic with regard to concurrent calls to Add.
+func (i *ShardedInt) Set(val int64) {
+	atomic.StoreInt64(&i.shards[0].v, val)
+	for j := 1; j < len(i.shards); j++ {
+		atomic.StoreInt64(&i.shards[j].v, 0)
+	}
+}
+
+// Value returns the sum of all shards.
+func (i *ShardedInt) Value() (res int64) {
+	for j := range i.shards {
+		res += atomic.LoadInt64(&i.shards[j].v)
+	}
+	return
+}
+
+// Items returns the value in a 1-size KeyValue slice.
+func (i *ShardedInt) Items() []KeyValue { return formatSamples(i.Samples()) }
+
+// Samples returns the value in a 1-size Sample slice.
+func (i *ShardedInt) Samples() []Sample {
+	return []Sample{{
+		Key:   i.key,
+		Value: Int64Value(i.Value()),
+		Kind:  KindUntyped,
+	}}
+}
+
+type floatShard struct {
+	f uint64
+	_ cacheLinePad
+}
+
+// ShardedFloat is a 64-bit float variable that satisfies the Var interface.
+//
+// It behaves like Float but spreads writes across multiple shards combined in Items.
+type ShardedFloat struct {
+	key    string
+	shards []floatShard
+}
+
+// NewShardedFloat creates a ShardedFloat and publishes it.
+func NewShardedFloat(name string) *ShardedFloat {
+	f := &ShardedFloat{key: name}
+	f.Init()
+	Publish(f)
+
+	return f
+}
+
+// Init initializes the shards. Must be called before attempting to set a value.
+// Note that NewShardedFloat already initializes the shards.
+func (f *ShardedFloat) Init() *ShardedFloat {
+	f.shards = make([]floatShard, numShards())
+	return f
+}
+
+// Add atomically adds `delta` to the value.
+func (f *ShardedFloat) Add(delta float64) {
+	s := &f.shards[shardIndex(len(f.shards)-1)]
+	for {
+		cur := atomic.LoadUint64(&s.f)
+		nxt := math.Float64bits(math.Float64frombits(cur) + delta)
+		if atomic.CompareAndSwapUint64(&s.f, cur, nxt) {
+			return
+		}
+	}
+}
+
+// Set sets the value to `val`.
+// Unlike Add, it is not atomic with regard to concurrent calls to Add.
+func (f *ShardedFloat) Set(val float64) {
+	atomic.StoreUint64(&f.shards[0].f, math.Float64bits(val))
+	for j := 1; j < len(f.shards); j++ {
+		atomic.StoreUint64(&f.shards[j].f, 0)
+	}
+}
+
+// Value returns the sum of all shards.
+func (f *ShardedFloat) Value() (res float64) {
+	for j := range f.shards {
+		res += math.Float64frombits(atomic.LoadUint64(&f.shards[j].f))
+	}
+	return
+}
+
+// Items returns the value in a 1-size KeyValue slice.
+func (f *ShardedFloat) Items() []KeyValue { return formatSamples(f.Samples()) }
+
+// Samples returns the value in a 1-size Sample slice.
+func (f *ShardedFloat) Samples() []Sample {
+	return []Sample{{
+		Key:   f.key,
+		Value: Float64Value(f.Value()),
+		Kind:  KindGauge,
+	}}
+}
+
+type histogramShard struct {
+	mu      sync.Mutex
+	buffer  []int64
+	counter int64
+	sum     int64
+	_       cacheLinePad
+}
+
+// ShardedHistogram is a histogram that satisfies the Var interface.
+//
+// It behaves like Histogram but each shard has its own lock and ring buffer;
+// the buffers are combined in Items to compute the statistics.
+type ShardedHistogram struct {
+	shards []histogramShard
+
+	mu sync.Mutex
+	h  Histogram
+}
+
+// NewShardedHistogram creates a ShardedHistogram and publishes it.
+// The buffer of `bufferSize` values is split evenly between the shards.
+func NewShardedHistogram(name string, bufferSize int) *ShardedHistogram {
+	h := &ShardedHistogram{}
+	h.h.key = name
+	h.Init(bufferSize)
+	Publish(h)
+
+	return h
+}
+
+// Init initializes the shards. Must be called before attempting to record a value.
+// Note that NewShardedHistogram already initializes the shards.
+func (h *ShardedHistogram) Init(bufferSize int) *ShardedHistogram {
+	h.shards = make([]histogramShard, numShards())
+
+	size := bufferSize / len(h.shards)
+	if size < 1 {
+		size = 1
+	}
+	for i := range h.shards {
+		h.shards[i].buffer = make([]int64, size)
+	}
+
+	h.h.Init(0)
+	h.h.snapshot = make([]int64, size*len(h.shards))
+
+	return h
+}
+
+// Configure sets the statistics and percentiles reported by the histogram.
+// See Histogram.Configure.
+func (h *ShardedHistogram) Configure(stats HistogramStat, percentiles ...float64) *ShardedHistogram {
+	h.h.Configure(stats, percentiles...)
+	return h
+}
+
+func (h *ShardedHistogram) Record(val int64) {
+	s := &h.shards[shardIndex(len(h.shards)-1)]
+
+	s.mu.Lock()
+
+	idx := int(s.counter % int64(len(s.buffer)))
+	s.buffer[idx] = val
+	s.counter++
+	s.sum += val
+
+	s.mu.Unlock()
+}
+
+func (h *ShardedHistogram) RecordSince(t time.Time) {
+	h.Record(int64(time.Since(t)))
+}
+
+func (h *ShardedHistogram) Items() []KeyValue { return formatSamples(h.Samples()) }
+
+// Samples returns the configured statistics and percentiles of the values recorded in every shard.
+func (h *ShardedHistogram) Samples() []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := h.h.snapshot[:0]
+	var count, sum int64
+	for i := range h.shards {
+		s := &h.shards[i]
+
+		s.mu.Lock()
+		snapshot = append(snapshot, s.buffer...)
+		count += s.counter
+		sum += s.sum
+		s.mu.Unlock()
+	}
+
+	h.h.mu.Lock()
+	stats, percentiles := h.h.stats, h.h.percentiles
+	h.h.mu.Unlock()
+
+	h.h.snapshotCount = count
+	h.h.snapshotSum = sum
+
+	return h.h.samples(stats, percentiles)
+}
+
+var (
+	_ Var = (*ShardedInt)(nil)
+	_ Var = (*ShardedFloat)(nil)
+	_ Var = (*ShardedHistogram)(nil)
+
+	_ Sampler = (*ShardedInt)(nil)
+	_ Sampler = (*ShardedFloat)(nil)
+	_ Sampler = (*ShardedHistogram)(nil)
+)
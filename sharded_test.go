@@ -0,0 +1,132 @@
+package mgr
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedInt(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	i := NewShardedInt("foobar")
+	i.Set(50)
+
+	var wg sync.WaitGroup
+	wg.Add(4000)
+	for j := 0; j < 4000; j++ {
+		go func() {
+			i.Add(1)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	config := &Config{Clock: fakeClock(100)}
+
+	err := report(config)
+	require.Nil(t, err)
+	require.Equal(t, "foobar 4050 100\n", buf.String())
+
+	i.Set(10)
+	require.Equal(t, int64(10), i.Value())
+}
+
+func TestShardedFloat(t *testing.T) {
+	f := NewShardedFloat("foobar")
+	f.Set(0.5)
+
+	var wg sync.WaitGroup
+	wg.Add(4000)
+	for j := 0; j < 4000; j++ {
+		go func() {
+			f.Add(1)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, []KeyValue{{"foobar", "4000.5"}}, f.Items())
+}
+
+func TestShardedHistogram(t *testing.T) {
+	h := NewShardedHistogram("foobar", 1).Configure(StatMax|StatMin|StatCount|StatSum, 50)
+
+	var wg sync.WaitGroup
+	wg.Add(100)
+	for j := 0; j < 100; j++ {
+		go func() {
+			h.Record(3)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, []KeyValue{
+		{"foobar.max", "3"},
+		{"foobar.min", "3"},
+		{"foobar.count", "100"},
+		{"foobar.sum", "300"},
+		{"foobar.p50", "3"},
+	}, h.Items())
+}
+
+func BenchmarkIntAdd(b *testing.B) {
+	var i Int
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i.Add(1)
+		}
+	})
+}
+
+func BenchmarkShardedIntAdd(b *testing.B) {
+	var i ShardedInt
+	i.Init()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i.Add(1)
+		}
+	})
+}
+
+func BenchmarkFloatAdd(b *testing.B) {
+	var f Float
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f.Add(1)
+		}
+	})
+}
+
+func BenchmarkShardedFloatAdd(b *testing.B) {
+	var f ShardedFloat
+	f.Init()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f.Add(1)
+		}
+	})
+}
+
+func BenchmarkHistogramRecord(b *testing.B) {
+	var h Histogram
+	h.Init(1024)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.Record(1)
+		}
+	})
+}
+
+func BenchmarkShardedHistogramRecord(b *testing.B) {
+	var h ShardedHistogram
+	h.Init(1024)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.Record(1)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package mgr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSpansKey is the key under which the span histograms are reported unless configured otherwise.
+	DefaultSpansKey = "spans"
+	// DefaultMaxSpans is the maximum number of span histograms unless configured otherwise.
+	DefaultMaxSpans = 1000
+	// DefaultSpanBufferSize is the buffer size of the span histograms unless configured otherwise.
+	DefaultSpanBufferSize = 1024
+
+	// OtherSpan is the path under which spans are recorded once the maximum number of histograms is reached.
+	OtherSpan = "other"
+)
+
+type spanPathKey struct{}
+
+var spans = struct {
+	mu         sync.Mutex
+	key        string
+	maxSpans   int
+	bufferSize int
+	m          *Map
+	histograms map[string]*Histogram
+}{
+	key:        DefaultSpansKey,
+	maxSpans:   DefaultMaxSpans,
+	bufferSize: DefaultSpanBufferSize,
+}
+
+// ConfigureSpans sets the key under which the span histograms are reported, the maximum number
+// of histograms and their buffer size. It must be called before recording the first span.
+func ConfigureSpans(key string, maxSpans, bufferSize int) {
+	spans.mu.Lock()
+	defer spans.mu.Unlock()
+
+	spans.key = key
+	spans.maxSpans = maxSpans
+	spans.bufferSize = bufferSize
+}
+
+// SpanPath returns the path of the span carried by `ctx`, or an empty string if there is none.
+func SpanPath(ctx context.Context) string {
+	path, _ := ctx.Value(spanPathKey{}).(string)
+	return path
+}
+
+// StartSpan starts timing the span `name`, nested in the span carried by `ctx` if any.
+// It returns a context carrying the span, to start nested spans, and a function recording its duration.
+//
+// The durations are recorded in nanoseconds in a Histogram created the first time the span path is seen,
+// for example the span "payment" started with a context carrying the span "checkout" is reported as:
+//
+//	spans.checkout.payment.<stat>
+//
+// Once the maximum number of histograms is reached, new span paths are recorded under OtherSpan.
+// See ConfigureSpans.
+func StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	path := JoinKey(SpanPath(ctx), name)
+	h := spanHistogram(path)
+
+	start := time.Now()
+	return context.WithValue(ctx, spanPathKey{}, path), func() { h.RecordSince(start) }
+}
+
+// Span starts timing the span `name`, nested in the span carried by `ctx` if any, and returns a function recording its duration:
+//
+//	defer mgr.Span(ctx, "checkout.payment")()
+//
+// See StartSpan.
+func Span(ctx context.Context, name string) func() {
+	_, done := StartSpan(ctx, name)
+	return done
+}
+
+// spanHistogram returns the histogram of the span `path`, creating it if needed.
+// The span histograms are published the first time a span is recorded.
+func spanHistogram(path string) *Histogram {
+	spans.mu.Lock()
+	defer spans.mu.Unlock()
+
+	if spans.m == nil {
+		spans.m = NewMap(spans.key)
+		spans.histograms = make(map[string]*Histogram)
+	}
+
+	if h, ok := spans.histograms[path]; ok {
+		return h
+	}
+	if len(spans.histograms) >= spans.maxSpans && path != OtherSpan {
+		if h, ok := spans.histograms[OtherSpan]; ok {
+			return h
+		}
+		path = OtherSpan
+	}
+
+	h := new(Histogram).Init(spans.bufferSize)
+	spans.histograms[path] = h
+	spans.m.Set(path, h)
+
+	return h
+}
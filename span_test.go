@@ -0,0 +1,56 @@
+package mgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resetSpans(maxSpans int) {
+	spans.m = nil
+	spans.histograms = nil
+	ConfigureSpans(DefaultSpansKey, maxSpans, 16)
+}
+
+func TestSpan(t *testing.T) {
+	_, fn := reset()
+	defer fn()
+	resetSpans(DefaultMaxSpans)
+
+	ctx, done := StartSpan(context.Background(), "checkout")
+	require.Equal(t, "checkout", SpanPath(ctx))
+
+	for i := 0; i < 3; i++ {
+		Span(ctx, "payment")()
+	}
+	done()
+
+	require.Equal(t, "", SpanPath(context.Background()))
+	require.Len(t, vars.l, 1)
+	require.Equal(t, int64(3), spans.histograms["checkout.payment"].counter)
+	require.Equal(t, int64(1), spans.histograms["checkout"].counter)
+
+	keys := make(map[string]bool)
+	for _, item := range spans.m.Items() {
+		keys[item.Key] = true
+	}
+	require.True(t, keys["spans.checkout.payment.p99"])
+	require.True(t, keys["spans.checkout.mean"])
+}
+
+func TestSpanMaxSpans(t *testing.T) {
+	_, fn := reset()
+	defer fn()
+	resetSpans(2)
+
+	Span(context.Background(), "a")()
+	Span(context.Background(), "b")()
+	Span(context.Background(), "c")()
+	Span(context.Background(), "d")()
+	Span(context.Background(), "a")()
+
+	require.Len(t, spans.histograms, 3)
+	require.Equal(t, int64(2), spans.histograms["a"].counter)
+	require.Equal(t, int64(2), spans.histograms[OtherSpan].counter)
+}
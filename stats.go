@@ -0,0 +1,121 @@
+package mgr
+
+import (
+	"sync"
+	"time"
+)
+
+// ExporterStats are statistics about the exporter itself.
+type ExporterStats struct {
+	// Exports is the number of exports attempted.
+	Exports int64
+	// Failures is the number of exports which failed, even partially.
+	Failures int64
+	// LastError is the time of the last failed export, as stamped on its data.
+	LastError time.Time
+	// BytesSent is the number of bytes successfully written.
+	BytesSent int64
+	// LinesSent is the number of lines successfully written.
+	LinesSent int64
+	// LastExportDuration is the duration of the last export.
+	LastExportDuration time.Duration
+	// Reconnects is the number of connections made to the Graphite server after the first one.
+	Reconnects int64
+	// DroppedPoints is the number of lines lost because the Graphite server couldn't be reached or their batch couldn't be written.
+	DroppedPoints int64
+	// Vars is the number of published variables as of the last export.
+	Vars int
+}
+
+type statsRecorder struct {
+	mu                  sync.Mutex
+	connections         int64
+	consecutiveFailures int64
+	values              ExporterStats
+}
+
+var exporterStats statsRecorder
+
+// Stats returns the statistics of the exporter.
+func Stats() ExporterStats {
+	exporterStats.mu.Lock()
+	defer exporterStats.mu.Unlock()
+
+	return exporterStats.values
+}
+
+func (r *statsRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.connections = 0
+	r.consecutiveFailures = 0
+	r.values = ExporterStats{}
+}
+
+// recordConnection records a successful connection and returns true if it's a reconnection,
+// that is if a connection was made before.
+func (r *statsRecorder) recordConnection() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.connections++
+	if r.connections > 1 {
+		r.values.Reconnects++
+	}
+
+	return r.connections > 1
+}
+
+// record records an export done at `now` and returns the number of consecutive failed exports, including this one.
+func (r *statsRecorder) record(b *batch, vars int, now time.Time, d time.Duration, err error) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &r.values
+	s.Exports++
+	if err != nil {
+		s.Failures++
+		s.LastError = now
+		r.consecutiveFailures++
+	} else {
+		r.consecutiveFailures = 0
+	}
+	s.BytesSent += b.bytesSent
+	s.LinesSent += b.linesSent
+	s.DroppedPoints += b.droppedLines
+	s.LastExportDuration = d
+	s.Vars = vars
+
+	return r.consecutiveFailures
+}
+
+func (r *statsRecorder) samples(prefix string) []Sample {
+	r.mu.Lock()
+	s := r.values
+	r.mu.Unlock()
+
+	var lastError int64
+	if !s.LastError.IsZero() {
+		lastError = s.LastError.Unix()
+	}
+
+	counter := func(name string, v int64) Sample {
+		return Sample{Key: JoinKey(prefix, name), Value: Int64Value(v), Kind: KindCounter}
+	}
+	gauge := func(name string, v int64) Sample {
+		return Sample{Key: JoinKey(prefix, name), Value: Int64Value(v), Kind: KindGauge}
+	}
+
+	return []Sample{
+		counter("exports", s.Exports),
+		counter("failures", s.Failures),
+		gauge("last_error", lastError),
+		counter("bytes_sent", s.BytesSent),
+		counter("lines_sent", s.LinesSent),
+		gauge("export_duration_ns", int64(s.LastExportDuration)),
+		counter("reconnects", s.Reconnects),
+		counter("dropped_points", s.DroppedPoints),
+		gauge("vars", int64(s.Vars)),
+	}
+}
@@ -0,0 +1,116 @@
+package mgr
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	w := writesRecorder{fail: map[int]bool{1: true}}
+	_, fn := resetWithRecorder(&w)
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+
+	err := report(&Config{Clock: fakeClock(100), MaxBatchLines: 1})
+	require.NotNil(t, err)
+
+	s := Stats()
+	require.Equal(t, int64(1), s.Exports)
+	require.Equal(t, int64(1), s.Failures)
+	require.Equal(t, time.Unix(100, 0), s.LastError)
+	require.Equal(t, int64(8), s.BytesSent)
+	require.Equal(t, int64(1), s.LinesSent)
+	require.Equal(t, int64(0), s.Reconnects)
+	require.Equal(t, int64(1), s.DroppedPoints)
+	require.Equal(t, 2, s.Vars)
+
+	err = report(&Config{Clock: fakeClock(100)})
+	require.Nil(t, err)
+
+	s = Stats()
+	require.Equal(t, int64(2), s.Exports)
+	require.Equal(t, int64(1), s.Failures)
+	require.Equal(t, int64(24), s.BytesSent)
+	require.Equal(t, int64(3), s.LinesSent)
+	require.Equal(t, int64(1), s.Reconnects)
+}
+
+func TestStatsDialFailures(t *testing.T) {
+	w := writesRecorder{fail: map[int]bool{0: true}}
+	_, fn := resetWithRecorder(&w)
+	defer fn()
+
+	NewInt("a")
+	NewInt("b")
+
+	dial := dialFn
+	dialFn = func(_ *Config) (io.Writer, error) { return nil, errors.New("connection refused") }
+
+	config := &Config{Clock: fakeClock(100)}
+	for i := 0; i < 3; i++ {
+		require.NotNil(t, report(config))
+	}
+
+	s := Stats()
+	require.Equal(t, int64(3), s.Failures)
+	require.Equal(t, int64(6), s.DroppedPoints)
+	require.Equal(t, int64(0), s.LinesSent)
+	require.Equal(t, int64(0), s.Reconnects)
+
+	// The first connection isn't a reconnection, the one following the failed write is.
+	dialFn = dial
+	require.NotNil(t, report(config))
+	require.Nil(t, report(config))
+
+	s = Stats()
+	require.Equal(t, int64(8), s.DroppedPoints)
+	require.Equal(t, int64(2), s.LinesSent)
+	require.Equal(t, int64(1), s.Reconnects)
+}
+
+func TestStatsPrefix(t *testing.T) {
+	buf, fn := reset()
+	defer fn()
+
+	NewInt("a")
+
+	config := &Config{Clock: fakeClock(100), StatsPrefix: "mgr"}
+	require.Nil(t, report(config))
+	buf.Reset()
+	require.Nil(t, report(config))
+
+	var keys []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.Fields(line)
+		keys = append(keys, fields[0])
+
+		switch fields[0] {
+		case "mgr.exports", "mgr.vars":
+			require.Equal(t, "1", fields[1])
+		case "mgr.lines_sent":
+			require.Equal(t, "10", fields[1])
+		case "mgr.failures", "mgr.reconnects", "mgr.dropped_points":
+			require.Equal(t, "0", fields[1])
+		}
+	}
+
+	require.Equal(t, []string{
+		"a",
+		"mgr.exports",
+		"mgr.failures",
+		"mgr.last_error",
+		"mgr.bytes_sent",
+		"mgr.lines_sent",
+		"mgr.export_duration_ns",
+		"mgr.reconnects",
+		"mgr.dropped_points",
+		"mgr.vars",
+	}, keys)
+}
@@ -0,0 +1,364 @@
+package mgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCompression is the compression used by a TDigest unless configured otherwise.
+// Higher values trade memory for accuracy, a digest keeps in the order of 2 * compression centroids.
+const DefaultCompression = 100
+
+// ErrInvalidDigest is returned when unmarshalling a malformed serialized digest.
+var ErrInvalidDigest = errors.New("invalid digest")
+
+const tdigestEncodingVersion = 1
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+type centroids []centroid
+
+func (c centroids) Len() int           { return len(c) }
+func (c centroids) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c centroids) Less(i, j int) bool { return c[i].mean < c[j].mean }
+
+// TDigest is a streaming quantile sketch that satisfies the Var interface.
+//
+// It tracks quantiles in bounded memory using the merging t-digest algorithm.
+// Unlike Histogram, digests from different hosts can be combined with Merge, use
+// MarshalBinary to ship a digest to a downstream aggregator.
+type TDigest struct {
+	key string
+
+	mu          sync.Mutex
+	compression float64
+	percentiles []float64
+
+	merged   centroids
+	unmerged centroids
+	count    float64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+// NewTDigest creates a TDigest reporting the given percentiles and publishes it.
+// If no percentile is given, DefaultPercentiles are reported.
+func NewTDigest(name string, compression float64, percentiles ...float64) *TDigest {
+	d := &TDigest{key: name}
+	d.Init(compression, percentiles...)
+	Publish(d)
+
+	return d
+}
+
+// Init initializes the digest. Must be called before attempting to add a value.
+// Note that NewTDigest already initializes the digest.
+func (d *TDigest) Init(compression float64, percentiles ...float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+
+	checkPercentiles(percentiles)
+
+	d.compression = compression
+	d.percentiles = append([]float64(nil), percentiles...)
+	d.reset()
+
+	return d
+}
+
+func (d *TDigest) reset() {
+	d.merged = make(centroids, 0, int(math.Ceil(d.compression))*2)
+	d.unmerged = make(centroids, 0, int(math.Ceil(d.compression))*8)
+	d.count = 0
+	d.sum = 0
+	d.min = math.Inf(1)
+	d.max = math.Inf(-1)
+}
+
+// Add adds the value `val` to the digest.
+func (d *TDigest) Add(val float64) {
+	d.mu.Lock()
+	d.add(val, 1)
+	d.mu.Unlock()
+}
+
+// AddSince adds the time elapsed since `t` in nanoseconds to the digest.
+func (d *TDigest) AddSince(t time.Time) {
+	d.Add(float64(time.Since(t)))
+}
+
+func (d *TDigest) add(mean, weight float64) {
+	if math.IsNaN(mean) || weight <= 0 {
+		return
+	}
+
+	d.unmerged = append(d.unmerged, centroid{mean, weight})
+	d.count += weight
+	d.sum += mean * weight
+	if mean < d.min {
+		d.min = mean
+	}
+	if mean > d.max {
+		d.max = mean
+	}
+
+	if len(d.unmerged) == cap(d.unmerged) {
+		d.compress()
+	}
+}
+
+// k is the scale function limiting the size of the centroids: centroids near the
+// tails are kept small so that extreme quantiles stay accurate.
+func (d *TDigest) k(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *TDigest) kInverse(k float64) float64 {
+	if k >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/d.compression) + 1) / 2
+}
+
+func (d *TDigest) compress() {
+	if len(d.unmerged) == 0 {
+		return
+	}
+
+	all := append(d.unmerged, d.merged...)
+	sort.Sort(all)
+
+	merged := make(centroids, 0, cap(d.merged))
+	cur := all[0]
+	weightSoFar := 0.0
+	limit := d.kInverse(d.k(0) + 1)
+
+	for _, c := range all[1:] {
+		if (weightSoFar+cur.weight+c.weight)/d.count <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+
+		merged = append(merged, cur)
+		weightSoFar += cur.weight
+		limit = d.kInverse(d.k(weightSoFar/d.count) + 1)
+		cur = c
+	}
+	merged = append(merged, cur)
+
+	d.merged = merged
+	d.unmerged = d.unmerged[:0]
+}
+
+// Merge adds every value of the digest `other` to the digest.
+func (d *TDigest) Merge(other *TDigest) {
+	other.mu.Lock()
+	other.compress()
+	cs := append(centroids(nil), other.merged...)
+	min, max := other.min, other.max
+	other.mu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range cs {
+		d.add(c.mean, c.weight)
+	}
+	if min < d.min {
+		d.min = min
+	}
+	if max > d.max {
+		d.max = max
+	}
+}
+
+// Count returns the number of values added to the digest.
+func (d *TDigest) Count() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.count
+}
+
+// Quantile returns the estimated value at the quantile `q`, which must be between 0 and 1.
+// It returns NaN if the digest is empty.
+func (d *TDigest) Quantile(q float64) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.compress()
+
+	return d.quantile(q)
+}
+
+func (d *TDigest) quantile(q float64) float64 {
+	cs := d.merged
+	switch {
+	case len(cs) == 0:
+		return math.NaN()
+	case len(cs) == 1 || q <= 0:
+		if q >= 1 {
+			return d.max
+		}
+		if q <= 0 {
+			return d.min
+		}
+		return cs[0].mean
+	case q >= 1:
+		return d.max
+	}
+
+	index := q * d.count
+
+	// Before the center of the first centroid, interpolate with the minimum.
+	if index < cs[0].weight/2 {
+		return d.min + (cs[0].mean-d.min)*index/(cs[0].weight/2)
+	}
+
+	cumulative := cs[0].weight / 2
+	for i := 0; i < len(cs)-1; i++ {
+		dw := (cs[i].weight + cs[i+1].weight) / 2
+		if cumulative+dw > index {
+			z := (index - cumulative) / dw
+			return cs[i].mean + z*(cs[i+1].mean-cs[i].mean)
+		}
+		cumulative += dw
+	}
+
+	// After the center of the last centroid, interpolate with the maximum.
+	last := cs[len(cs)-1]
+	z := (index - cumulative) / (last.weight / 2)
+	if z > 1 {
+		z = 1
+	}
+	return last.mean + z*(d.max-last.mean)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (d *TDigest) MarshalBinary() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.compress()
+
+	buf := make([]byte, 1, 1+8*4+binary.MaxVarintLen64+16*len(d.merged))
+	buf[0] = tdigestEncodingVersion
+
+	putFloat := func(f float64) {
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+
+	putFloat(d.compression)
+	putFloat(d.sum)
+	putFloat(d.min)
+	putFloat(d.max)
+	buf = binary.AppendUvarint(buf, uint64(len(d.merged)))
+	for _, c := range d.merged {
+		putFloat(c.mean)
+		putFloat(c.weight)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// The compression and centroids of the digest are replaced by the serialized ones,
+// the reported percentiles are left unchanged.
+func (d *TDigest) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 || data[0] != tdigestEncodingVersion {
+		return ErrInvalidDigest
+	}
+	data = data[1:]
+
+	getFloat := func() (float64, bool) {
+		if len(data) < 8 {
+			return 0, false
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(data))
+		data = data[8:]
+		return f, true
+	}
+
+	var header [4]float64
+	for i := range header {
+		f, ok := getFloat()
+		if !ok {
+			return ErrInvalidDigest
+		}
+		header[i] = f
+	}
+
+	if header[0] <= 0 {
+		return ErrInvalidDigest
+	}
+
+	n, l := binary.Uvarint(data)
+	if l <= 0 || n > uint64(len(data)) || uint64(len(data)-l) != n*16 {
+		return ErrInvalidDigest
+	}
+	data = data[l:]
+
+	cs := make(centroids, n)
+	var count float64
+	for i := range cs {
+		cs[i].mean, _ = getFloat()
+		cs[i].weight, _ = getFloat()
+		count += cs[i].weight
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.compression = header[0]
+	d.reset()
+	d.merged = append(d.merged, cs...)
+	d.count = count
+	d.sum = header[1]
+	d.min = header[2]
+	d.max = header[3]
+
+	return nil
+}
+
+// Items returns the count and sum of the values added to the digest followed by its percentiles.
+func (d *TDigest) Items() []KeyValue { return formatSamples(d.Samples()) }
+
+// Samples returns the same values as Items, the count and sum as counters and the percentiles as gauges.
+func (d *TDigest) Samples() []Sample {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.compress()
+
+	res := make([]Sample, 0, len(d.percentiles)+2)
+	res = append(res,
+		Sample{Key: JoinKey(d.key, "count"), Value: Float64Value(d.count), Kind: KindCounter},
+		Sample{Key: JoinKey(d.key, "sum"), Value: Float64Value(d.sum), Kind: KindCounter},
+	)
+	if d.count == 0 {
+		return res
+	}
+	for _, p := range d.percentiles {
+		res = append(res, Sample{Key: JoinKey(d.key, percentileName(p)), Value: Float64Value(d.quantile(p / 100)), Kind: KindGauge})
+	}
+
+	return res
+}
+
+var (
+	_ Var     = (*TDigest)(nil)
+	_ Sampler = (*TDigest)(nil)
+)
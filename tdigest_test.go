@@ -0,0 +1,90 @@
+package mgr
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTDigestQuantile(t *testing.T) {
+	var d TDigest
+	d.Init(100)
+
+	for i := 1; i <= 100000; i++ {
+		d.Add(float64(i))
+	}
+
+	require.Equal(t, float64(100000), d.Count())
+	require.InEpsilon(t, 50000, d.Quantile(0.5), 0.01)
+	require.InEpsilon(t, 99000, d.Quantile(0.99), 0.001)
+	require.InEpsilon(t, 99900, d.Quantile(0.999), 0.001)
+	require.Equal(t, float64(1), d.Quantile(0))
+	require.Equal(t, float64(100000), d.Quantile(1))
+	require.True(t, len(d.merged) < 300)
+}
+
+func TestTDigestEmpty(t *testing.T) {
+	d := NewTDigest("foobar", 0)
+
+	require.True(t, math.IsNaN(d.Quantile(0.5)))
+	require.Equal(t, []KeyValue{
+		{"foobar.count", "0"},
+		{"foobar.sum", "0"},
+	}, d.Items())
+}
+
+func TestTDigestMerge(t *testing.T) {
+	var d1, d2 TDigest
+	d1.Init(100)
+	d2.Init(100)
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50000; i++ {
+		d1.Add(r.Float64() * 1000)
+		d2.Add(1000 + r.Float64()*1000)
+	}
+
+	d1.Merge(&d2)
+
+	require.Equal(t, float64(100000), d1.Count())
+	require.InDelta(t, 1000, d1.Quantile(0.5), 20)
+	require.InDelta(t, 1900, d1.Quantile(0.95), 20)
+}
+
+func TestTDigestMarshalBinary(t *testing.T) {
+	var d TDigest
+	d.Init(50)
+	for i := 0; i < 10000; i++ {
+		d.Add(float64(i))
+	}
+
+	data, err := d.MarshalBinary()
+	require.Nil(t, err)
+
+	var d2 TDigest
+	d2.Init(100)
+	require.Nil(t, d2.UnmarshalBinary(data))
+
+	require.Equal(t, d.Count(), d2.Count())
+	require.Equal(t, d.Quantile(0.9), d2.Quantile(0.9))
+	require.Equal(t, float64(50), d2.compression)
+
+	require.Equal(t, ErrInvalidDigest, d2.UnmarshalBinary(nil))
+	require.Equal(t, ErrInvalidDigest, d2.UnmarshalBinary(data[:len(data)-1]))
+}
+
+func TestTDigestItems(t *testing.T) {
+	d := NewTDigest("foobar", 100, 50, 99.9)
+	for i := 1; i <= 3; i++ {
+		d.Add(float64(i))
+	}
+
+	require.Equal(t, []KeyValue{
+		{"foobar.count", "3"},
+		{"foobar.sum", "6"},
+		{"foobar.p50", "2"},
+		{"foobar.p999", "3"},
+	}, d.Items())
+}
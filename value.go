@@ -0,0 +1,220 @@
+package mgr
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Kind describes how a metric behaves over time.
+type Kind uint8
+
+const (
+	// KindUntyped is used when the kind of a metric is unknown, for example for variables only implementing Var.
+	KindUntyped Kind = iota
+	// KindCounter is a metric that only goes up, like a number of requests.
+	KindCounter
+	// KindGauge is a metric that can go up and down, like a number of connections.
+	KindGauge
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	default:
+		return "untyped"
+	}
+}
+
+// ValueType is the type of a Value.
+type ValueType uint8
+
+const (
+	TypeInt64 ValueType = iota
+	TypeUint64
+	TypeFloat64
+	TypeBool
+	// TypeString is only used for values reported by variables only implementing Var
+	// which can't be parsed as a number or a boolean.
+	TypeString
+)
+
+// Value is a typed metric value.
+// The zero value is the int64 0.
+type Value struct {
+	typ  ValueType
+	bits uint64
+	// raw, if set, is the formatting of the value, for example as reported by a variable only implementing Var.
+	raw string
+}
+
+// Int64Value returns a Value holding the int64 `v`.
+func Int64Value(v int64) Value { return Value{typ: TypeInt64, bits: uint64(v)} }
+
+// Uint64Value returns a Value holding the uint64 `v`.
+func Uint64Value(v uint64) Value { return Value{typ: TypeUint64, bits: v} }
+
+// Float64Value returns a Value holding the float64 `v`.
+func Float64Value(v float64) Value { return Value{typ: TypeFloat64, bits: math.Float64bits(v)} }
+
+// BoolValue returns a Value holding the bool `v`.
+func BoolValue(v bool) Value {
+	if v {
+		return Value{typ: TypeBool, bits: 1}
+	}
+	return Value{typ: TypeBool}
+}
+
+// roundedFloat64Value returns a Value holding the float64 `v`, formatted with 5 significant digits.
+func roundedFloat64Value(v float64) Value {
+	res := Float64Value(v)
+	res.raw = strconv.FormatFloat(v, 'g', 5, 64)
+
+	return res
+}
+
+// parseValue converts a value formatted by a variable only implementing Var.
+// The original formatting is kept so that it is reported unchanged.
+func parseValue(s string) Value {
+	var v Value
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		v = Int64Value(i)
+	} else if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		v = Uint64Value(u)
+	} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+		v = Float64Value(f)
+	} else if b, err := strconv.ParseBool(s); err == nil {
+		v = BoolValue(b)
+	} else {
+		v.typ = TypeString
+	}
+	v.raw = s
+
+	return v
+}
+
+// Type returns the type of the value.
+func (v Value) Type() ValueType { return v.typ }
+
+// Int64 returns the value as an int64.
+func (v Value) Int64() int64 {
+	switch v.typ {
+	case TypeFloat64:
+		return int64(v.Float64())
+	default:
+		return int64(v.bits)
+	}
+}
+
+// Uint64 returns the value as an uint64.
+func (v Value) Uint64() uint64 {
+	switch v.typ {
+	case TypeFloat64:
+		return uint64(v.Float64())
+	default:
+		return v.bits
+	}
+}
+
+// Float64 returns the value as a float64.
+func (v Value) Float64() float64 {
+	switch v.typ {
+	case TypeInt64:
+		return float64(int64(v.bits))
+	case TypeUint64, TypeBool:
+		return float64(v.bits)
+	case TypeFloat64:
+		return math.Float64frombits(v.bits)
+	default:
+		return math.NaN()
+	}
+}
+
+// Bool returns the value as a bool: true for any value other than 0.
+func (v Value) Bool() bool {
+	return v.typ != TypeString && v.Float64() != 0
+}
+
+// String formats the value.
+func (v Value) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+
+	switch v.typ {
+	case TypeUint64:
+		return strconv.FormatUint(v.bits, 10)
+	case TypeFloat64:
+		return strconv.FormatFloat(v.Float64(), 'g', -1, 64)
+	case TypeBool:
+		return strconv.FormatBool(v.bits != 0)
+	default:
+		return strconv.FormatInt(int64(v.bits), 10)
+	}
+}
+
+// Sample is a single typed metric.
+type Sample struct {
+	Key   string
+	Value Value
+	Kind  Kind
+	// Timestamp is the time at which the value was sampled.
+	// If zero, the sample is stamped with the time of the export.
+	Timestamp time.Time
+}
+
+// Sampler is implemented by variables reporting typed samples.
+//
+// All variables of this package implement it. Custom variables only implementing Var
+// are still supported: the values of their items are parsed and reported as untyped.
+type Sampler interface {
+	Var
+	Samples() []Sample
+}
+
+// samples returns the samples of the variable `v`.
+func samples(v Var) []Sample {
+	if s, ok := v.(Sampler); ok {
+		return s.Samples()
+	}
+
+	items := v.Items()
+	res := make([]Sample, len(items))
+	for i, item := range items {
+		res[i] = Sample{
+			Key:   item.Key,
+			Value: parseValue(item.Value),
+		}
+	}
+
+	return res
+}
+
+// formatSamples converts the samples to items, used by the Items method of the variables implementing Sampler.
+func formatSamples(samples []Sample) []KeyValue {
+	res := make([]KeyValue, len(samples))
+	for i, s := range samples {
+		res[i] = KeyValue{
+			Key:   s.Key,
+			Value: s.Value.String(),
+		}
+	}
+
+	return res
+}
+
+// JoinKey joins the key of a variable and the name of one of its samples with a dot,
+// omitting the dot when either is empty.
+func JoinKey(key, name string) string {
+	switch {
+	case key == "":
+		return name
+	case name == "":
+		return key
+	default:
+		return key + "." + name
+	}
+}
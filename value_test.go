@@ -0,0 +1,82 @@
+package mgr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueString(t *testing.T) {
+	require.Equal(t, "-10", Int64Value(-10).String())
+	require.Equal(t, "18446744073709551615", Uint64Value(math.MaxUint64).String())
+	require.Equal(t, "0.25", Float64Value(0.25).String())
+	require.Equal(t, "true", BoolValue(true).String())
+	require.Equal(t, "0", Value{}.String())
+}
+
+func TestParseValue(t *testing.T) {
+	v := parseValue("-10")
+	require.Equal(t, TypeInt64, v.Type())
+	require.Equal(t, int64(-10), v.Int64())
+
+	v = parseValue("18446744073709551615")
+	require.Equal(t, TypeUint64, v.Type())
+	require.Equal(t, uint64(math.MaxUint64), v.Uint64())
+
+	v = parseValue("1.50")
+	require.Equal(t, TypeFloat64, v.Type())
+	require.Equal(t, 1.5, v.Float64())
+	require.Equal(t, "1.50", v.String())
+
+	v = parseValue("false")
+	require.Equal(t, TypeBool, v.Type())
+	require.False(t, v.Bool())
+
+	v = parseValue("foo")
+	require.Equal(t, TypeString, v.Type())
+	require.Equal(t, "foo", v.String())
+}
+
+func TestJoinKey(t *testing.T) {
+	require.Equal(t, "foo.bar", JoinKey("foo", "bar"))
+	require.Equal(t, "bar", JoinKey("", "bar"))
+	require.Equal(t, "foo", JoinKey("foo", ""))
+}
+
+func TestSamplesCompat(t *testing.T) {
+	f := Func(func() []KeyValue {
+		return []KeyValue{{"foo", "10"}, {"bar", "1.5"}}
+	})
+
+	require.Equal(t, []Sample{
+		{Key: "foo", Value: parseValue("10")},
+		{Key: "bar", Value: parseValue("1.5")},
+	}, samples(f))
+}
+
+func TestMapSamples(t *testing.T) {
+	var (
+		i Int
+		f Float
+		h Histogram
+	)
+
+	i.Set(10)
+	f.Set(0.5)
+	h.Init(4).Configure(StatCount)
+	h.Record(20)
+
+	var m Map
+	m.Init().Set("i", &i)
+	m.Set("f", &f)
+	m.Set("h", &h)
+	m.Set("legacy", Func(func() []KeyValue { return []KeyValue{{"", "3"}} }))
+
+	require.Equal(t, []Sample{
+		{Key: "f", Value: Float64Value(0.5), Kind: KindGauge},
+		{Key: "h.count", Value: Int64Value(1), Kind: KindCounter},
+		{Key: "i", Value: Int64Value(10), Kind: KindUntyped},
+		{Key: "legacy", Value: parseValue("3"), Kind: KindUntyped},
+	}, m.Samples())
+}